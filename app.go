@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// App wires together everything the server and the one-shot commands
+// share.
+type App struct {
+	cfg     Config
+	db      *sql.DB
+	client  *HttpClient
+	usecase *Usecase
+	repo    *Repo
+	movieC  *MovieCrwaler
+	showC   *ShowCrwaler
+	personC *PersonCrawler
+	orgC    *CompanyCrawler
+	imdbI   *IMDBImporter
+	refS    *ReferenceSyncer
+	chartS  *ChartSyncer
+	discS   *Discoverer
+	staleR  *StaleRefresher
+	manager *ScrapeManager
+}
+
+func NewApp(cfg Config) (*App, error) {
+	log.Println("Connecting to db")
+	db, err := sql.Open("postgres", cfg.DBURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	log.Println("Connected to Postgres.")
+	repo := NewRepo(db)
+	err = repo.CreateDb(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	client := NewClient(time.Duration(cfg.TMDB.RateDelay), time.Duration(cfg.TMDB.RequestTimeout))
+	var archive *Repo
+	if cfg.TMDB.ArchiveResponses {
+		archive = repo
+	}
+	var drift *Repo
+	if cfg.TMDB.DetectDrift {
+		drift = repo
+	}
+	breaker := NewCircuitBreaker(cfg.TMDB.BreakerAuthFailures, cfg.TMDB.BreakerErrors, time.Duration(cfg.TMDB.BreakerProbeInterval))
+	uc := NewUsecase(cfg.TMDB.BaseURL, client, time.Duration(cfg.TMDB.RequestTimeout), archive, drift, cfg.Crawl.IncludeSpecials, breaker)
+
+	validator := NewValidator(repo, cfg.Validation.Rules, cfg.Validation.Reject)
+
+	mc := NewMovieCrawler(
+		uc,
+		cfg.TMDB.AccessToken,
+		repo,
+		validator,
+		cfg.Crawl.MovieMaxID,
+		cfg.Crawl.Languages,
+		time.Duration(cfg.Crawl.CollectionTTL),
+	)
+	sc := NewShowCrawler(
+		uc,
+		cfg.TMDB.AccessToken,
+		repo,
+		validator,
+		cfg.Crawl.ShowMaxID,
+		cfg.Crawl.Languages,
+	)
+	pc := NewPersonCrawler(
+		uc,
+		cfg.TMDB.AccessToken,
+		repo,
+		validator,
+		cfg.Crawl.PersonMaxID,
+	)
+
+	oc := NewCompanyCrawler(uc, cfg.TMDB.AccessToken, repo)
+
+	imdbI := NewIMDbImporter(db, cfg.DataDir, cfg.IMDb.URL, time.Duration(cfg.IMDb.Interval))
+
+	refS := NewReferenceSyncer(uc, cfg.TMDB.AccessToken, repo, time.Duration(cfg.Reference.Interval))
+
+	chartS := NewChartSyncer(
+		uc,
+		cfg.TMDB.AccessToken,
+		repo,
+		time.Duration(cfg.Charts.Interval),
+		cfg.Charts.Pages,
+		mc,
+		sc,
+		pc,
+	)
+
+	discS := NewDiscoverer(uc, cfg.TMDB.AccessToken, repo, mc, sc)
+
+	staleR := NewStaleRefresher(
+		repo,
+		time.Duration(cfg.Refresh.Interval),
+		cfg.Refresh.Budget,
+		time.Duration(cfg.Refresh.MinAge),
+		mc,
+		sc,
+		pc,
+	)
+
+	return &App{
+		cfg:     cfg,
+		db:      db,
+		client:  client,
+		usecase: uc,
+		repo:    repo,
+		movieC:  mc,
+		showC:   sc,
+		personC: pc,
+		orgC:    oc,
+		imdbI:   imdbI,
+		refS:    refS,
+		chartS:  chartS,
+		discS:   discS,
+		staleR:  staleR,
+		manager: NewScrapeManager(sc, mc, pc, oc, imdbI, refS, chartS, discS, staleR, repo, breaker),
+	}, nil
+}
+
+// Close releases the TMDB client and the database. Jobs must have been
+// stopped before.
+func (a *App) Close() {
+	a.client.Close()
+	a.db.Close()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBreakerAuthFailures  = 5
+	DefaultBreakerErrors        = 50
+	DefaultBreakerProbeInterval = time.Minute
+)
+
+// CircuitBreaker stops the TMDB requests of every job once TMDB keeps
+// rejecting the access token or keeps failing, so the crawls don't fill
+// failed with items that were never really tried. While it is open
+// requests wait, every probe interval one of them is let through as a
+// probe and the first one TMDB answers closes it again.
+type CircuitBreaker struct {
+	authLimit     int
+	errorLimit    int
+	probeInterval time.Duration
+
+	mtx          *sync.Mutex
+	authFailures int
+	errors       int
+	open         bool
+	reason       string
+	openedAt     time.Time
+	nextProbe    time.Time
+	probing      bool
+	// changed is closed and replaced whenever waiting requests should look
+	// at the state again.
+	changed chan struct{}
+}
+
+// BreakerState is what /stats and /jobs show about the breaker.
+type BreakerState struct {
+	Open      bool       `json:"open"`
+	Reason    string     `json:"reason,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+	NextProbe *time.Time `json:"next_probe,omitempty"`
+}
+
+// NewCircuitBreaker trips after authLimit unauthorized responses or
+// errorLimit failed requests in a row.
+func NewCircuitBreaker(authLimit int, errorLimit int, probeInterval time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		authLimit:     authLimit,
+		errorLimit:    errorLimit,
+		probeInterval: probeInterval,
+		mtx:           &sync.Mutex{},
+		changed:       make(chan struct{}),
+	}
+}
+
+// Acquire waits until a request may be sent. probe reports that the
+// request is the probe of an open breaker, its outcome decides whether the
+// breaker closes.
+func (b *CircuitBreaker) Acquire(ctx context.Context) (probe bool, err error) {
+	for {
+		b.mtx.Lock()
+		if !b.open {
+			b.mtx.Unlock()
+			return false, nil
+		}
+		wait := b.probeInterval
+		if !b.probing {
+			wait = time.Until(b.nextProbe)
+			if wait <= 0 {
+				b.probing = true
+				b.mtx.Unlock()
+				return true, nil
+			}
+		}
+		changed := b.changed
+		b.mtx.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Record reports the outcome of a request Acquire let through. While the
+// breaker is open only the probe counts, and only a 200 closes it. Requests
+// that were already in flight when it tripped say nothing about whether
+// TMDB is back.
+func (b *CircuitBreaker) Record(probe bool, err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.open {
+		if !probe {
+			return
+		}
+		b.probing = false
+		b.nextProbe = time.Now().Add(b.probeInterval)
+		b.signal()
+		if err != nil {
+			fmt.Println("TMDB probe failed, staying paused:", err)
+			return
+		}
+		fmt.Println("TMDB answered again after", time.Since(b.openedAt).Round(time.Second), "resuming requests")
+		b.open = false
+		b.reason = ""
+		b.authFailures = 0
+		b.errors = 0
+		return
+	}
+
+	switch errorKind(err) {
+	case ErrUnauthorized:
+		// 401 and 403, see checkStatus.
+		b.authFailures++
+		b.errors++
+	case ErrRateLimited, ErrUpstream5xx, ErrNetwork:
+		b.authFailures = 0
+		b.errors++
+	case ErrNotFound:
+		// TMDB answered and took the token, the item just isn't there.
+		b.authFailures = 0
+		b.errors = 0
+		return
+	default:
+		if err == nil {
+			b.authFailures = 0
+			b.errors = 0
+		}
+		// Any other status, or cancelled and never sent, says nothing
+		// about TMDB.
+		return
+	}
+
+	switch {
+	case b.authFailures >= b.authLimit:
+		b.trip(fmt.Sprintf("TMDB rejected the access token %d times in a row (%v), check TMDB_AT", b.authFailures, err))
+	case b.errors >= b.errorLimit:
+		b.trip(fmt.Sprintf("%d TMDB requests in a row failed, the last with %v", b.errors, err))
+	}
+}
+
+// trip must be called with mtx held.
+func (b *CircuitBreaker) trip(reason string) {
+	fmt.Println("Pausing TMDB requests:", reason)
+	b.open = true
+	b.reason = reason
+	b.openedAt = time.Now()
+	b.nextProbe = b.openedAt.Add(b.probeInterval)
+}
+
+// signal must be called with mtx held.
+func (b *CircuitBreaker) signal() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+func (b *CircuitBreaker) State() BreakerState {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if !b.open {
+		return BreakerState{}
+	}
+	since := b.openedAt
+	next := b.nextProbe
+	return BreakerState{
+		Open:      true,
+		Reason:    b.reason,
+		Since:     &since,
+		NextProbe: &next,
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func apiErr(kind ErrorKind, status int) error {
+	return &APIError{Kind: kind, Status: status, Endpoint: "/movie/550"}
+}
+
+// openBreaker trips a breaker on unauthorized responses.
+func openBreaker(t *testing.T, probeInterval time.Duration) *CircuitBreaker {
+	t.Helper()
+	b := NewCircuitBreaker(2, 10, probeInterval)
+	b.Record(false, apiErr(ErrUnauthorized, 401))
+	b.Record(false, apiErr(ErrUnauthorized, 403))
+	if !b.State().Open {
+		t.Fatal("breaker did not trip on sustained 401/403")
+	}
+	return b
+}
+
+func TestBreakerIgnoresInFlightOutcomesWhileOpen(t *testing.T) {
+	b := openBreaker(t, time.Hour)
+
+	for _, err := range []error{nil, apiErr(ErrNotFound, 404), apiErr(ErrStatus, 400)} {
+		b.Record(false, err)
+		if !b.State().Open {
+			t.Fatalf("non-probe outcome %v closed the breaker", err)
+		}
+	}
+}
+
+func TestBreakerProbe(t *testing.T) {
+	b := openBreaker(t, time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, err := range []error{apiErr(ErrNotFound, 404), apiErr(ErrStatus, 400), apiErr(ErrUnauthorized, 403)} {
+		probe, aerr := b.Acquire(ctx)
+		if aerr != nil || !probe {
+			t.Fatalf("Acquire() = %v, %v, want a probe", probe, aerr)
+		}
+		b.Record(probe, err)
+		if !b.State().Open {
+			t.Fatalf("probe answered with %v closed the breaker", err)
+		}
+	}
+
+	probe, err := b.Acquire(ctx)
+	if err != nil || !probe {
+		t.Fatalf("Acquire() = %v, %v, want a probe", probe, err)
+	}
+	b.Record(probe, nil)
+	if b.State().Open {
+		t.Fatal("successful probe did not close the breaker")
+	}
+	if probe, err := b.Acquire(ctx); err != nil || probe {
+		t.Errorf("Acquire() after closing = %v, %v, want a plain request", probe, err)
+	}
+}
+
+func TestBreakerStatusDoesNotResetAuthFailures(t *testing.T) {
+	b := NewCircuitBreaker(2, 10, time.Hour)
+	b.Record(false, apiErr(ErrUnauthorized, 401))
+	b.Record(false, apiErr(ErrStatus, 400))
+	b.Record(false, apiErr(ErrUnauthorized, 403))
+	if !b.State().Open {
+		t.Error("a 400 between auth failures kept the breaker closed")
+	}
+}
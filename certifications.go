@@ -0,0 +1,40 @@
+package main
+
+import "tmdb_scraper/models"
+
+// theatricalRelease is the ReleaseDate type whose certification wins when
+// a country has several releases.
+const theatricalRelease = 3
+
+// movieCertifications picks one certification per country from the
+// release dates of a movie, preferring the theatrical release.
+func movieCertifications(rd models.ReleaseDates) map[string]string {
+	res := make(map[string]string)
+	for _, country := range rd.Results {
+		best := ""
+		bestType := int64(0)
+		for _, r := range country.ReleaseDates {
+			if r.Certification == "" {
+				continue
+			}
+			if best == "" || (r.Type == theatricalRelease && bestType != theatricalRelease) {
+				best = r.Certification
+				bestType = r.Type
+			}
+		}
+		if best != "" {
+			res[country.ISO3166_1] = best
+		}
+	}
+	return res
+}
+
+func showCertifications(cr models.ContentRatings) map[string]string {
+	res := make(map[string]string)
+	for _, r := range cr.Results {
+		if r.Rating != "" {
+			res[r.ISO3166_1] = r.Rating
+		}
+	}
+	return res
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxChartPages is the deepest page TMDB serves for its lists.
+const MaxChartPages = 500
+
+// Charts are the lists ChartSyncer snapshots, named by their api path.
+var Charts = []string{
+	"trending/all/day",
+	"trending/all/week",
+	"trending/movie/day",
+	"trending/movie/week",
+	"trending/tv/day",
+	"trending/tv/week",
+	"trending/person/day",
+	"trending/person/week",
+	"movie/popular",
+	"movie/now_playing",
+	"movie/upcoming",
+	"tv/on_the_air",
+	"tv/airing_today",
+}
+
+func validChart(chart string) bool {
+	for _, c := range Charts {
+		if c == chart {
+			return true
+		}
+	}
+	return false
+}
+
+// ChartRow is one ranked entry of a snapshot.
+type ChartRow struct {
+	Rank       int     `json:"rank"`
+	Type       string  `json:"type"`
+	ID         int     `json:"id"`
+	Title      string  `json:"title"`
+	Popularity float64 `json:"popularity"`
+}
+
+// ChartSyncer takes dated snapshots of the trending and popular lists and
+// fetches the entries that aren't stored yet.
+type ChartSyncer struct {
+	usecase  *Usecase
+	at       string
+	repo     *Repo
+	interval time.Duration
+	pages    int
+	fetchers map[string]func(ctx context.Context, v int, opts FetchOptions) error
+}
+
+func NewChartSyncer(
+	usecase *Usecase,
+	at string,
+	repo *Repo,
+	interval time.Duration,
+	pages int,
+	movieC *MovieCrwaler,
+	showC *ShowCrwaler,
+	personC *PersonCrawler,
+) *ChartSyncer {
+	return &ChartSyncer{
+		usecase:  usecase,
+		at:       at,
+		repo:     repo,
+		interval: interval,
+		pages:    pages,
+		fetchers: map[string]func(ctx context.Context, v int, opts FetchOptions) error{
+			"movie":  movieC.Fetch,
+			"show":   showC.Fetch,
+			"person": personC.Fetch,
+		},
+	}
+}
+
+// Start takes a snapshot once and then again on every interval until ctx
+// is cancelled. A failed snapshot, the first one included, is retried on
+// the next tick. The entries that aren't stored yet are queued and fetched
+// in the background, so a long backlog doesn't hold up the next snapshot.
+func (s *ChartSyncer) Start(ctx context.Context) error {
+	queue := newChartQueue()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.drain(ctx, queue)
+	}()
+
+	snapshot := func() {
+		items, err := s.snapshotAll(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Println("Chart snapshot failed", err)
+			}
+			return
+		}
+		queue.push(items)
+	}
+
+	snapshot()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			snapshot()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// SyncOnce snapshots every chart under today's UTC date, replacing an
+// earlier snapshot of the same day, then fetches the unseen entries before
+// it returns.
+func (s *ChartSyncer) SyncOnce(ctx context.Context) error {
+	items, err := s.snapshotAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		if err := s.fetchCharted(ctx, it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chartItem is a charted movie, show or person.
+type chartItem struct {
+	tp string
+	id int
+}
+
+// snapshotAll snapshots every chart under today's UTC date, replacing an
+// earlier snapshot of the same day, and returns the charted items once
+// each.
+func (s *ChartSyncer) snapshotAll(ctx context.Context) ([]chartItem, error) {
+	date := time.Now().UTC().Truncate(24 * time.Hour)
+	var items []chartItem
+	seen := make(map[chartItem]bool)
+
+	for _, chart := range Charts {
+		rows, err := s.snapshot(ctx, chart)
+		if err != nil {
+			return nil, err
+		}
+		err = s.repo.StoreChartSnapshot(ctx, chart, date, rows)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("Stored %d entries of %s\n", len(rows), chart)
+
+		for _, r := range rows {
+			it := chartItem{tp: r.Type, id: r.ID}
+			if !seen[it] {
+				seen[it] = true
+				items = append(items, it)
+			}
+		}
+	}
+	return items, nil
+}
+
+// fetchCharted fetches a charted item unless it is stored or known not to
+// exist. It only fails when ctx is done.
+func (s *ChartSyncer) fetchCharted(ctx context.Context, it chartItem) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	exists, err := s.repo.ItemExists(ctx, it.tp, it.id)
+	if err != nil {
+		fmt.Println("Error getting item exists", err)
+		return nil
+	}
+	if exists {
+		return nil
+	}
+	exists, err = s.repo.NotFoundExists(ctx, it.tp, it.id)
+	if err != nil {
+		fmt.Println("Error getting not found", err)
+		return nil
+	}
+	if exists {
+		return nil
+	}
+	stored, err := fetchListed(ctx, s.fetchers[it.tp], it.id, FetchOptions{})
+	if stored {
+		fmt.Println("Fetched charted", it.tp, it.id)
+	}
+	return err
+}
+
+// drain fetches the queued items until ctx is done.
+func (s *ChartSyncer) drain(ctx context.Context, q *chartQueue) {
+	for {
+		it, ok := q.pop()
+		if !ok {
+			select {
+			case <-q.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := s.fetchCharted(ctx, it); err != nil {
+			return
+		}
+	}
+}
+
+// chartQueue holds the charted items waiting to be fetched, each item at
+// most once.
+type chartQueue struct {
+	mtx    *sync.Mutex
+	items  []chartItem
+	queued map[chartItem]bool
+	// wake is signalled when items are pushed.
+	wake chan struct{}
+}
+
+func newChartQueue() *chartQueue {
+	return &chartQueue{
+		mtx:    &sync.Mutex{},
+		queued: make(map[chartItem]bool),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+func (q *chartQueue) push(items []chartItem) {
+	q.mtx.Lock()
+	added := 0
+	for _, it := range items {
+		if !q.queued[it] {
+			q.queued[it] = true
+			q.items = append(q.items, it)
+			added++
+		}
+	}
+	waiting := len(q.items)
+	q.mtx.Unlock()
+
+	fmt.Printf("Queued %d charted items, %d waiting to be fetched\n", added, waiting)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *chartQueue) pop() (chartItem, bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if len(q.items) == 0 {
+		return chartItem{}, false
+	}
+	it := q.items[0]
+	q.items = q.items[1:]
+	delete(q.queued, it)
+	return it, true
+}
+
+// snapshot reads the configured number of pages of a chart. Entries that
+// moved between two page requests are only ranked once.
+func (s *ChartSyncer) snapshot(ctx context.Context, chart string) ([]ChartRow, error) {
+	var rows []ChartRow
+	ranked := make(map[string]bool)
+	for page := 1; page <= s.pages; page++ {
+		res, err := s.usecase.GetChartPage(ctx, chart, page, s.at)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range res.Results {
+			tp := chartEntryType(chart, e.MediaType)
+			key := fmt.Sprintf("%s/%d", tp, e.ID)
+			if tp == "" || ranked[key] {
+				continue
+			}
+			ranked[key] = true
+			title := e.Title
+			if title == "" {
+				title = e.Name
+			}
+			rows = append(rows, ChartRow{
+				Rank:       len(rows) + 1,
+				Type:       tp,
+				ID:         int(e.ID),
+				Title:      title,
+				Popularity: e.Popularity,
+			})
+		}
+		if int64(page) >= res.TotalPages {
+			break
+		}
+	}
+	return rows, nil
+}
+
+// chartEntryType maps an entry to movie, show or person. The mixed
+// trending/all lists tell by media_type, the others by their path.
+func chartEntryType(chart string, mediaType string) string {
+	if mediaType == "" {
+		parts := strings.Split(chart, "/")
+		mediaType = parts[0]
+		if mediaType == "trending" {
+			mediaType = parts[1]
+		}
+	}
+	switch mediaType {
+	case "movie", "person":
+		return mediaType
+	case "tv":
+		return "show"
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChartQueue(t *testing.T) {
+	q := newChartQueue()
+	movie := chartItem{tp: "movie", id: 550}
+	show := chartItem{tp: "show", id: 1399}
+	person := chartItem{tp: "person", id: 287}
+
+	q.push([]chartItem{movie, show})
+	q.push([]chartItem{show, person})
+
+	select {
+	case <-q.wake:
+	default:
+		t.Fatal("push did not wake the queue")
+	}
+
+	var got []chartItem
+	for {
+		it, ok := q.pop()
+		if !ok {
+			break
+		}
+		got = append(got, it)
+	}
+	if want := []chartItem{movie, show, person}; !reflect.DeepEqual(got, want) {
+		t.Errorf("popped %v, want %v", got, want)
+	}
+
+	// Once fetched an item can be charted again.
+	q.push([]chartItem{movie})
+	if it, ok := q.pop(); !ok || it != movie {
+		t.Errorf("pop() = %v, %v, want %v", it, ok, movie)
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const usage = `Usage: tmdb_scraper [command] [arguments] [flags]
+
+Commands:
+  serve                                   run the HTTP API (default)
+  crawl movie|show|person [-start N] [-end N] [-overwrite] [-referenced]
+        [-deep] [-languages de,fr-FR]     crawl a range of ids and exit
+  crawl company|network [-overwrite]      crawl the companies or networks found
+                                          in stored movies and shows
+  discover movie|show [-genres 18,10765] [-year-from N] [-year-to N]
+        [-language ko] [-region KR] [-vote-average-gte N] [-vote-count-gte N]
+        [-sort popularity.desc] [-overwrite] [-deep] [-languages de,fr-FR]
+                                          crawl every item /discover matches
+  fetch movie|show|person ID [-deep] [-languages de,fr-FR]
+                                          fetch and store a single item
+  import imdb|reference|charts            download and import IMDb ratings, the
+                                          genres, countries, languages,
+                                          configuration and certifications, or a
+                                          snapshot of the trending and popular
+                                          lists once
+  reprocess [-type TYPE] [-id N] [-deep]  rebuild stored items from the archived
+                                          raw responses without asking TMDB
+  refresh-stale [-type TYPE] [-budget N]  refetch the stalest stored items once
+  retry-failed [-type TYPE] [-kind KIND]  fetch items from the failed table again
+  export [-type TYPE] [-out FILE]         write stored details as JSON lines
+  migrate                                 create or update the database schema
+  stats                                   print crawl progress
+  config show                             print the effective config, secrets redacted
+
+Every command accepts the config flags, see "tmdb_scraper <command> -h".
+`
+
+func runCommand(args []string) int {
+	cmd := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "serve":
+		err = cmdServe(args)
+	case "crawl":
+		err = cmdCrawl(args)
+	case "discover":
+		err = cmdDiscover(args)
+	case "fetch":
+		err = cmdFetch(args)
+	case "import":
+		err = cmdImport(args)
+	case "reprocess":
+		err = cmdReprocess(args)
+	case "refresh-stale":
+		err = cmdRefreshStale(args)
+	case "retry-failed":
+		err = cmdRetryFailed(args)
+	case "export":
+		err = cmdExport(args)
+	case "migrate":
+		err = cmdMigrate(args)
+	case "stats":
+		err = cmdStats(args)
+	case "config":
+		err = cmdConfig(args)
+	case "help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		return 2
+	}
+
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// splitPositional takes the n leading positional arguments off args so
+// that flags can follow them, as in "fetch movie 550 -config x.yaml".
+func splitPositional(args []string, n int, usage string) ([]string, []string, error) {
+	if len(args) < n {
+		return nil, nil, fmt.Errorf("usage: tmdb_scraper %s", usage)
+	}
+	for _, a := range args[:n] {
+		if strings.HasPrefix(a, "-") {
+			return nil, nil, fmt.Errorf("usage: tmdb_scraper %s", usage)
+		}
+	}
+	return args[:n], args[n:], nil
+}
+
+// setup loads and validates the config from args and builds the App.
+func setup(fs *flag.FlagSet, args []string) (*App, error) {
+	cfg, err := LoadConfig(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return NewApp(cfg)
+}
+
+// fetchOptionFlags registers the per job FetchOptions on fs.
+func fetchOptionFlags(fs *flag.FlagSet) *FetchOptions {
+	opts := &FetchOptions{}
+	fs.BoolVar(&opts.Deep, "deep", false, "show only: fetch every episode with credits, images and external ids")
+	fs.Func("languages", "comma separated translations to store, e.g. de,fr-FR", func(s string) error {
+		languages, err := parseLanguages(s)
+		opts.Languages = languages
+		return err
+	})
+	return opts
+}
+
+// parseLanguages splits a comma separated list of language tags.
+func parseLanguages(s string) ([]string, error) {
+	languages := strings.Split(s, ",")
+	for _, l := range languages {
+		if !validLanguageTag(l) {
+			return nil, fmt.Errorf("%q is not a language tag like de or de-DE", l)
+		}
+	}
+	return languages, nil
+}
+
+// signalContext is cancelled on SIGINT/SIGTERM so one-shot commands stop
+// after their current item.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+func cmdServe(args []string) error {
+	app, err := setup(flag.NewFlagSet("serve", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+	return app.Serve()
+}
+
+func cmdCrawl(args []string) error {
+	pos, args, err := splitPositional(args, 1, "crawl movie|show|person|company|network [flags]")
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("crawl", flag.ContinueOnError)
+	start := fs.Int("start", 0, "first id, 0 resumes from the saved progress")
+	end := fs.Int("end", 0, "last id, 0 uses the configured max id")
+	overwrite := fs.Bool("overwrite", false, "refetch items that are already stored")
+	referenced := fs.Bool("referenced", false, "person only: crawl only people found in stored credits")
+	opts := fetchOptionFlags(fs)
+	app, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	switch pos[0] {
+	case "movie":
+		return app.movieC.Start(ctx, *start, *end, *overwrite, *opts)
+	case "show":
+		return app.showC.Start(ctx, *start, *end, *overwrite, *opts)
+	case "person":
+		return app.personC.Start(ctx, *start, *end, *overwrite, *referenced, *opts)
+	case "company", "network":
+		return app.orgC.Start(ctx, pos[0], *overwrite)
+	}
+	return fmt.Errorf("Invalid type %s", pos[0])
+}
+
+func cmdDiscover(args []string) error {
+	pos, args, err := splitPositional(args, 1, "discover movie|show [flags]")
+	if err != nil {
+		return err
+	}
+
+	spec := DiscoverSpec{Type: pos[0]}
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	fs.Func("genres", "comma separated genre ids that must all match", func(s string) error {
+		for _, g := range strings.Split(s, ",") {
+			id, err := strconv.Atoi(g)
+			if err != nil {
+				return fmt.Errorf("invalid genre id %q", g)
+			}
+			spec.Genres = append(spec.Genres, id)
+		}
+		return nil
+	})
+	fs.IntVar(&spec.YearFrom, "year-from", 0, "first release or first air year")
+	fs.IntVar(&spec.YearTo, "year-to", 0, "last release or first air year")
+	fs.StringVar(&spec.Language, "language", "", "original language, e.g. ko")
+	fs.StringVar(&spec.Region, "region", "", "release region of movies, origin country of shows, e.g. KR")
+	fs.Float64Var(&spec.VoteAverageGte, "vote-average-gte", 0, "minimum vote average")
+	fs.IntVar(&spec.VoteCountGte, "vote-count-gte", 0, "minimum vote count")
+	fs.StringVar(&spec.Sort, "sort", "", "TMDB sort_by, popularity.desc by default")
+	overwrite := fs.Bool("overwrite", false, "refetch items that are already stored")
+	opts := fetchOptionFlags(fs)
+	app, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	return app.discS.Start(ctx, spec, *overwrite, *opts)
+}
+
+func cmdFetch(args []string) error {
+	pos, args, err := splitPositional(args, 2, "fetch movie|show|person ID [flags]")
+	if err != nil {
+		return err
+	}
+	tp := pos[0]
+	if tp != "movie" && tp != "show" && tp != "person" {
+		return fmt.Errorf("Invalid type %s", tp)
+	}
+	id, err := strconv.Atoi(pos[1])
+	if err != nil {
+		return fmt.Errorf("Invalid id %s", pos[1])
+	}
+
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	opts := fetchOptionFlags(fs)
+	app, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	data, err := app.manager.Refresh(ctx, tp, id, *opts)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(data))
+	return err
+}
+
+func cmdImport(args []string) error {
+	pos, args, err := splitPositional(args, 1, "import imdb|reference|charts [flags]")
+	if err != nil {
+		return err
+	}
+	if pos[0] != "imdb" && pos[0] != "reference" && pos[0] != "charts" {
+		return fmt.Errorf("Invalid import source %s", pos[0])
+	}
+
+	app, err := setup(flag.NewFlagSet("import", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if pos[0] == "reference" {
+		return app.refS.SyncOnce(ctx)
+	}
+	if pos[0] == "charts" {
+		return app.chartS.SyncOnce(ctx)
+	}
+	return app.imdbI.SyncOnce(ctx)
+}
+
+func cmdReprocess(args []string) error {
+	fs := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	tp := fs.String("type", "", "movie, show or person, all of them when empty")
+	id := fs.Int("id", 0, "only reprocess this id, needs -type")
+	opts := fetchOptionFlags(fs)
+	app, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	if *id != 0 && *tp == "" {
+		return fmt.Errorf("-id needs -type")
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	types := []string{"movie", "show", "person"}
+	if *tp != "" {
+		types = []string{*tp}
+	}
+	p := NewReprocessor(app.usecase, app.repo, app.cfg)
+	for _, t := range types {
+		done, err := p.Run(ctx, t, *id, *opts)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reprocessed %d %s items\n", done, t)
+	}
+	return nil
+}
+
+func cmdRefreshStale(args []string) error {
+	fs := flag.NewFlagSet("refresh-stale", flag.ContinueOnError)
+	tp := fs.String("type", "", "movie, show or person, all of them when empty")
+	budget := fs.Int("budget", 0, "items to refetch, 0 uses the configured budget")
+	app, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	var types []string
+	if *tp != "" {
+		types = []string{*tp}
+	}
+	err = app.staleR.RunOnce(ctx, types, *budget)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func cmdRetryFailed(args []string) error {
+	fs := flag.NewFlagSet("retry-failed", flag.ContinueOnError)
+	tp := fs.String("type", "", "movie, show, episode, person, company, network or collection, all of them when empty")
+	kind := fs.String("kind", "", "only retry failures of this kind like network or upstream_5xx, all of them when empty")
+	app, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	if *kind != "" && !slices.Contains(ErrorKinds, ErrorKind(*kind)) {
+		return fmt.Errorf("invalid kind %s", *kind)
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	types := []string{"movie", "show", "episode", "person", "company", "network", "collection"}
+	if *tp != "" {
+		types = []string{*tp}
+	}
+	for _, t := range types {
+		recovered, err := app.manager.RetryFailed(ctx, t, ErrorKind(*kind))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Recovered %d failed %s items\n", recovered, t)
+	}
+	return nil
+}
+
+func cmdExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	tp := fs.String("type", "", "movie, show or person, everything when empty")
+	out := fs.String("out", "", "output file, stdout when empty")
+	app, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	w := os.Stdout
+	if *out != "" {
+		w, err = os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+
+	type Line struct {
+		Type   string          `json:"type"`
+		TmdbID int             `json:"tmdb_id"`
+		Data   json.RawMessage `json:"data"`
+	}
+	enc := json.NewEncoder(w)
+	count := 0
+	err = app.repo.ExportDetails(ctx, *tp, func(id int, t string, data []byte) error {
+		count++
+		return enc.Encode(Line{Type: t, TmdbID: id, Data: data})
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d items\n", count)
+	return nil
+}
+
+func cmdMigrate(args []string) error {
+	// NewApp already brings the details schema up to date.
+	app, err := setup(flag.NewFlagSet("migrate", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if err := app.imdbI.initSchema(ctx); err != nil {
+		return err
+	}
+	// Shows stored before the calendar existed aren't in it yet.
+	if err := app.repo.RebuildUpcomingEpisodes(ctx); err != nil {
+		return err
+	}
+	fmt.Println("Schema is up to date")
+	return nil
+}
+
+func cmdStats(args []string) error {
+	app, err := setup(flag.NewFlagSet("stats", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	stats, err := app.manager.GetStats(context.Background())
+	if err != nil {
+		return err
+	}
+	body, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(body))
+	return nil
+}
+
+// cmdConfig prints the effective configuration with secrets redacted.
+// Validation problems are reported after it but don't suppress it.
+func cmdConfig(args []string) error {
+	pos, args, err := splitPositional(args, 1, "config show [flags]")
+	if err != nil {
+		return err
+	}
+	if pos[0] != "show" {
+		return fmt.Errorf("unknown config command %s", pos[0])
+	}
+
+	cfg, err := LoadConfig(flag.NewFlagSet("config show", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Redacted().Write(os.Stdout); err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
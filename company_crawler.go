@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CompanyCrawler fetches the production companies and tv networks that
+// stored movies and shows refer to. tp is company or network throughout.
+type CompanyCrawler struct {
+	usecase *Usecase
+	at      string
+	repo    *Repo
+}
+
+func NewCompanyCrawler(usecase *Usecase, at string, repo *Repo) *CompanyCrawler {
+	return &CompanyCrawler{
+		usecase: usecase,
+		at:      at,
+		repo:    repo,
+	}
+}
+
+// Start visits every company or network id found in stored details. There
+// is no checkpoint, stored ids are skipped unless overwrite is set.
+func (m *CompanyCrawler) Start(ctx context.Context, tp string, overwrite bool) error {
+	if _, ok := orgTables[tp]; !ok {
+		return fmt.Errorf("Invalid type %s", tp)
+	}
+	fmt.Println("Starting crawler for", tp)
+
+	ids, err := m.repo.GetReferencedOrgIDs(ctx, tp)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	c := crawl{
+		repo:      m.repo,
+		tp:        tp,
+		overwrite: overwrite,
+		exists: func(ctx context.Context, v int) (bool, error) {
+			return m.repo.OrgExists(ctx, tp, v)
+		},
+		fetch: func(ctx context.Context, v int) error {
+			return m.Fetch(ctx, tp, v)
+		},
+	}
+	return c.run(ctx, 0, listPages(ids))
+}
+
+// Fetch downloads a single company or network and stores it, recording it
+// in not_found or failed when that does not work.
+func (m *CompanyCrawler) Fetch(ctx context.Context, tp string, v int) error {
+	storeCtx := storeContext(ctx)
+
+	var (
+		details any
+		err     error
+	)
+	switch tp {
+	case "company":
+		details, err = m.usecase.GetCompanyDetails(ctx, fmt.Sprintf("%d", v), m.at)
+	case "network":
+		details, err = m.usecase.GetNetworkDetails(ctx, fmt.Sprintf("%d", v), m.at)
+	default:
+		return fmt.Errorf("Invalid type %s", tp)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Println("Error getting", tp, "details for", v)
+		if isNotFound(err) {
+			m.repo.InsertNotFound(storeCtx, v, tp)
+		} else {
+			m.repo.InsertError(storeCtx, v, tp, err)
+		}
+		return err
+	}
+
+	bt, err := json.Marshal(details)
+	if err != nil {
+		fmt.Printf("Error marshalling %s data %d %v\n", tp, v, err)
+		m.repo.InsertError(storeCtx, v, tp, err)
+		return err
+	}
+
+	err = m.repo.StoreOrg(storeCtx, tp, v, bt)
+	if err != nil {
+		fmt.Println("Error storing data in db")
+		m.repo.InsertError(storeCtx, v, tp, err)
+		return err
+	}
+	return nil
+}
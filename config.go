@@ -0,0 +1,540 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultListenAddr  = ":6996"
+	DefaultTMDBBaseURL = "https://api.themoviedb.org/3"
+	DefaultDataDir     = "./data"
+	DefaultRateDelay   = 200 * time.Millisecond
+	DefaultMovieMaxID  = 2000000
+	DefaultShowMaxID   = 350000
+	DefaultPersonMaxID = 6000000
+	// DefaultShutdownTimeout bounds how long running jobs get to drain on
+	// exit.
+	DefaultShutdownTimeout = 30 * time.Second
+
+	DefaultCollectionTTL     = 7 * 24 * time.Hour
+	DefaultReferenceInterval = 24 * time.Hour
+	DefaultChartInterval     = 24 * time.Hour
+	DefaultChartPages        = 5
+	DefaultRefreshInterval   = 24 * time.Hour
+	DefaultRefreshBudget     = 1000
+	DefaultRefreshMinAge     = 24 * time.Hour
+)
+
+// Duration is a time.Duration that reads and writes as "200ms", "12h" etc.
+type Duration time.Duration
+
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", value.Line, value.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d *Duration) Set(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+type TMDBConfig struct {
+	AccessToken    string   `yaml:"access_token"`
+	BaseURL        string   `yaml:"base_url"`
+	RateDelay      Duration `yaml:"rate_delay"`
+	RequestTimeout Duration `yaml:"request_timeout"`
+	// ArchiveResponses keeps every raw response body in raw_responses so
+	// details can be rebuilt with the reprocess command. Nothing is ever
+	// deleted from raw_responses, so it is off unless asked for.
+	ArchiveResponses bool `yaml:"archive_responses"`
+	// DetectDrift compares the movie, show, season and collection payloads
+	// against the models and records the differences for GET /drift.
+	DetectDrift bool `yaml:"detect_drift"`
+	// The circuit breaker pauses every job after BreakerAuthFailures 401 or
+	// 403 responses or BreakerErrors failed requests in a row, and probes
+	// TMDB every BreakerProbeInterval until it answers again.
+	BreakerAuthFailures  int      `yaml:"breaker_auth_failures"`
+	BreakerErrors        int      `yaml:"breaker_errors"`
+	BreakerProbeInterval Duration `yaml:"breaker_probe_interval"`
+}
+
+type CrawlConfig struct {
+	MovieMaxID  int `yaml:"movie_max_id"`
+	ShowMaxID   int `yaml:"show_max_id"`
+	PersonMaxID int `yaml:"person_max_id"`
+	// CollectionTTL is how long a stored collection is reused before the
+	// next member movie fetches it again.
+	CollectionTTL Duration `yaml:"collection_ttl"`
+	// Languages are the "de" or "de-DE" style tags whose translations are
+	// stored when a job doesn't name its own. Empty stores all of them.
+	Languages []string `yaml:"languages"`
+	// IncludeSpecials also stores season 0 of shows, which holds the
+	// specials.
+	IncludeSpecials bool `yaml:"include_specials"`
+}
+
+type IMDbConfig struct {
+	URL      string   `yaml:"url"`
+	Interval Duration `yaml:"interval"`
+}
+
+// ReferenceConfig is about the lookup data synced by ReferenceSyncer.
+type ReferenceConfig struct {
+	Interval Duration `yaml:"interval"`
+}
+
+// ChartConfig is about the list snapshots taken by ChartSyncer.
+type ChartConfig struct {
+	Interval Duration `yaml:"interval"`
+	// Pages is how many pages of 20 entries are kept per list.
+	Pages int `yaml:"pages"`
+}
+
+// RefreshConfig is about the staleness driven refresh job.
+type RefreshConfig struct {
+	Interval Duration `yaml:"interval"`
+	// Budget is how many items one run refetches at most.
+	Budget int `yaml:"budget"`
+	// MinAge keeps items fetched more recently out of a run.
+	MinAge Duration `yaml:"min_age"`
+}
+
+// ValidationConfig is about the checks run on items before they are
+// stored.
+type ValidationConfig struct {
+	// Rules are the checks run, see ValidationRules. Empty runs all of them.
+	Rules []string `yaml:"rules"`
+	// Reject puts items that break a rule in failed instead of details.
+	Reject bool `yaml:"reject"`
+}
+
+type Config struct {
+	DBURL           string           `yaml:"db_url"`
+	ListenAddr      string           `yaml:"listen_addr"`
+	DataDir         string           `yaml:"data_dir"`
+	ShutdownTimeout Duration         `yaml:"shutdown_timeout"`
+	TMDB            TMDBConfig       `yaml:"tmdb"`
+	Crawl           CrawlConfig      `yaml:"crawl"`
+	IMDb            IMDbConfig       `yaml:"imdb"`
+	Reference       ReferenceConfig  `yaml:"reference"`
+	Charts          ChartConfig      `yaml:"charts"`
+	Refresh         RefreshConfig    `yaml:"refresh"`
+	Validation      ValidationConfig `yaml:"validation"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ListenAddr:      DefaultListenAddr,
+		DataDir:         DefaultDataDir,
+		ShutdownTimeout: Duration(DefaultShutdownTimeout),
+		TMDB: TMDBConfig{
+			BaseURL:        DefaultTMDBBaseURL,
+			RateDelay:      Duration(DefaultRateDelay),
+			RequestTimeout: Duration(RequestTimeout),
+
+			BreakerAuthFailures:  DefaultBreakerAuthFailures,
+			BreakerErrors:        DefaultBreakerErrors,
+			BreakerProbeInterval: Duration(DefaultBreakerProbeInterval),
+		},
+		Crawl: CrawlConfig{
+			MovieMaxID:    DefaultMovieMaxID,
+			ShowMaxID:     DefaultShowMaxID,
+			PersonMaxID:   DefaultPersonMaxID,
+			CollectionTTL: Duration(DefaultCollectionTTL),
+		},
+		IMDb: IMDbConfig{
+			URL:      ImdbURL,
+			Interval: Duration(UpdateInterval),
+		},
+		Reference: ReferenceConfig{
+			Interval: Duration(DefaultReferenceInterval),
+		},
+		Charts: ChartConfig{
+			Interval: Duration(DefaultChartInterval),
+			Pages:    DefaultChartPages,
+		},
+		Refresh: RefreshConfig{
+			Interval: Duration(DefaultRefreshInterval),
+			Budget:   DefaultRefreshBudget,
+			MinAge:   Duration(DefaultRefreshMinAge),
+		},
+	}
+}
+
+// LoadConfig builds the configuration from, in increasing order of
+// precedence, the defaults, the config file, environment variables and
+// command line flags. The config flags are registered on fs next to any
+// command specific flags the caller already defined, then args is parsed.
+func LoadConfig(fs *flag.FlagSet, args []string) (Config, error) {
+	cfg := DefaultConfig()
+
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file (env CONFIG_FILE)")
+
+	// Flags are parsed into a scratch config and only the ones that were
+	// actually passed are copied over, so they don't clobber file/env values
+	// with their zero defaults.
+	var flagCfg Config
+	fs.StringVar(&flagCfg.DBURL, "db-url", "", "postgres connection string (env DB_URL)")
+	fs.StringVar(&flagCfg.ListenAddr, "listen", "", "http listen address (env LISTEN_ADDR)")
+	fs.StringVar(&flagCfg.DataDir, "data-dir", "", "directory for downloaded files (env DATA_DIR)")
+	fs.Var(&flagCfg.ShutdownTimeout, "shutdown-timeout", "how long to wait for jobs on exit (env SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&flagCfg.TMDB.AccessToken, "tmdb-at", "", "TMDB read access token (env TMDB_AT)")
+	fs.StringVar(&flagCfg.TMDB.BaseURL, "tmdb-base-url", "", "TMDB api base url (env TMDB_BASE_URL)")
+	fs.Var(&flagCfg.TMDB.RateDelay, "rate-delay", "minimum delay between TMDB requests (env TMDB_RATE_DELAY)")
+	fs.Var(&flagCfg.TMDB.RequestTimeout, "request-timeout", "deadline for a single TMDB request (env TMDB_REQUEST_TIMEOUT)")
+	fs.BoolVar(&flagCfg.TMDB.ArchiveResponses, "archive-responses", false, "archive raw TMDB responses (env TMDB_ARCHIVE_RESPONSES)")
+	fs.BoolVar(&flagCfg.TMDB.DetectDrift, "detect-drift", false, "record payload fields the models don't know (env TMDB_DETECT_DRIFT)")
+	fs.IntVar(&flagCfg.TMDB.BreakerAuthFailures, "breaker-auth-failures", 0, "unauthorized responses in a row that pause the jobs (env TMDB_BREAKER_AUTH_FAILURES)")
+	fs.IntVar(&flagCfg.TMDB.BreakerErrors, "breaker-errors", 0, "failed requests in a row that pause the jobs (env TMDB_BREAKER_ERRORS)")
+	fs.Var(&flagCfg.TMDB.BreakerProbeInterval, "breaker-probe-interval", "how often paused jobs probe TMDB (env TMDB_BREAKER_PROBE_INTERVAL)")
+	fs.IntVar(&flagCfg.Crawl.MovieMaxID, "movie-max-id", 0, "default upper bound for movie crawls (env MOVIE_MAX_ID)")
+	fs.IntVar(&flagCfg.Crawl.ShowMaxID, "show-max-id", 0, "default upper bound for show crawls (env SHOW_MAX_ID)")
+	fs.IntVar(&flagCfg.Crawl.PersonMaxID, "person-max-id", 0, "default upper bound for person crawls (env PERSON_MAX_ID)")
+	fs.BoolVar(&flagCfg.Crawl.IncludeSpecials, "include-specials", false, "store the specials season of shows (env CRAWL_INCLUDE_SPECIALS)")
+	fs.Var(&flagCfg.Crawl.CollectionTTL, "collection-ttl", "how long a stored collection is reused (env COLLECTION_TTL)")
+	fs.StringVar(&flagCfg.IMDb.URL, "imdb-url", "", "IMDb ratings dataset url (env IMDB_URL)")
+	fs.Var(&flagCfg.IMDb.Interval, "imdb-interval", "interval between IMDb syncs (env IMDB_INTERVAL)")
+	fs.Var(&flagCfg.Reference.Interval, "reference-interval", "interval between reference data syncs (env REFERENCE_INTERVAL)")
+	fs.Var(&flagCfg.Charts.Interval, "chart-interval", "interval between list snapshots (env CHART_INTERVAL)")
+	fs.IntVar(&flagCfg.Charts.Pages, "chart-pages", 0, "pages kept per list snapshot (env CHART_PAGES)")
+	fs.Var(&flagCfg.Refresh.Interval, "refresh-interval", "interval between stale refresh runs (env REFRESH_INTERVAL)")
+	fs.IntVar(&flagCfg.Refresh.Budget, "refresh-budget", 0, "items refetched per stale refresh run (env REFRESH_BUDGET)")
+	fs.Var(&flagCfg.Refresh.MinAge, "refresh-min-age", "items fetched more recently are not refreshed (env REFRESH_MIN_AGE)")
+	fs.BoolVar(&flagCfg.Validation.Reject, "validation-reject", false, "store items that break a validation rule in failed (env VALIDATION_REJECT)")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "db-url":
+			cfg.DBURL = flagCfg.DBURL
+		case "listen":
+			cfg.ListenAddr = flagCfg.ListenAddr
+		case "data-dir":
+			cfg.DataDir = flagCfg.DataDir
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = flagCfg.ShutdownTimeout
+		case "tmdb-at":
+			cfg.TMDB.AccessToken = flagCfg.TMDB.AccessToken
+		case "tmdb-base-url":
+			cfg.TMDB.BaseURL = flagCfg.TMDB.BaseURL
+		case "rate-delay":
+			cfg.TMDB.RateDelay = flagCfg.TMDB.RateDelay
+		case "request-timeout":
+			cfg.TMDB.RequestTimeout = flagCfg.TMDB.RequestTimeout
+		case "archive-responses":
+			cfg.TMDB.ArchiveResponses = flagCfg.TMDB.ArchiveResponses
+		case "detect-drift":
+			cfg.TMDB.DetectDrift = flagCfg.TMDB.DetectDrift
+		case "breaker-auth-failures":
+			cfg.TMDB.BreakerAuthFailures = flagCfg.TMDB.BreakerAuthFailures
+		case "breaker-errors":
+			cfg.TMDB.BreakerErrors = flagCfg.TMDB.BreakerErrors
+		case "breaker-probe-interval":
+			cfg.TMDB.BreakerProbeInterval = flagCfg.TMDB.BreakerProbeInterval
+		case "movie-max-id":
+			cfg.Crawl.MovieMaxID = flagCfg.Crawl.MovieMaxID
+		case "show-max-id":
+			cfg.Crawl.ShowMaxID = flagCfg.Crawl.ShowMaxID
+		case "person-max-id":
+			cfg.Crawl.PersonMaxID = flagCfg.Crawl.PersonMaxID
+		case "include-specials":
+			cfg.Crawl.IncludeSpecials = flagCfg.Crawl.IncludeSpecials
+		case "collection-ttl":
+			cfg.Crawl.CollectionTTL = flagCfg.Crawl.CollectionTTL
+		case "imdb-url":
+			cfg.IMDb.URL = flagCfg.IMDb.URL
+		case "imdb-interval":
+			cfg.IMDb.Interval = flagCfg.IMDb.Interval
+		case "reference-interval":
+			cfg.Reference.Interval = flagCfg.Reference.Interval
+		case "chart-interval":
+			cfg.Charts.Interval = flagCfg.Charts.Interval
+		case "chart-pages":
+			cfg.Charts.Pages = flagCfg.Charts.Pages
+		case "refresh-interval":
+			cfg.Refresh.Interval = flagCfg.Refresh.Interval
+		case "refresh-budget":
+			cfg.Refresh.Budget = flagCfg.Refresh.Budget
+		case "refresh-min-age":
+			cfg.Refresh.MinAge = flagCfg.Refresh.MinAge
+		case "validation-reject":
+			cfg.Validation.Reject = flagCfg.Validation.Reject
+		}
+	})
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	err = dec.Decode(c)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) applyEnv() error {
+	strVars := map[string]*string{
+		"DB_URL":        &c.DBURL,
+		"LISTEN_ADDR":   &c.ListenAddr,
+		"DATA_DIR":      &c.DataDir,
+		"TMDB_AT":       &c.TMDB.AccessToken,
+		"TMDB_BASE_URL": &c.TMDB.BaseURL,
+		"IMDB_URL":      &c.IMDb.URL,
+	}
+	for k, v := range strVars {
+		if val, ok := os.LookupEnv(k); ok && val != "" {
+			*v = val
+		}
+	}
+
+	durVars := map[string]*Duration{
+		"SHUTDOWN_TIMEOUT":            &c.ShutdownTimeout,
+		"TMDB_RATE_DELAY":             &c.TMDB.RateDelay,
+		"TMDB_REQUEST_TIMEOUT":        &c.TMDB.RequestTimeout,
+		"IMDB_INTERVAL":               &c.IMDb.Interval,
+		"COLLECTION_TTL":              &c.Crawl.CollectionTTL,
+		"REFERENCE_INTERVAL":          &c.Reference.Interval,
+		"CHART_INTERVAL":              &c.Charts.Interval,
+		"REFRESH_INTERVAL":            &c.Refresh.Interval,
+		"REFRESH_MIN_AGE":             &c.Refresh.MinAge,
+		"TMDB_BREAKER_PROBE_INTERVAL": &c.TMDB.BreakerProbeInterval,
+	}
+	for k, v := range durVars {
+		if val, ok := os.LookupEnv(k); ok && val != "" {
+			if err := v.Set(val); err != nil {
+				return fmt.Errorf("config: env %s: %w", k, err)
+			}
+		}
+	}
+
+	if val, ok := os.LookupEnv("CRAWL_LANGUAGES"); ok && val != "" {
+		c.Crawl.Languages = strings.Split(val, ",")
+	}
+	if val, ok := os.LookupEnv("VALIDATION_RULES"); ok && val != "" {
+		c.Validation.Rules = strings.Split(val, ",")
+	}
+
+	boolVars := map[string]*bool{
+		"TMDB_ARCHIVE_RESPONSES": &c.TMDB.ArchiveResponses,
+		"TMDB_DETECT_DRIFT":      &c.TMDB.DetectDrift,
+		"VALIDATION_REJECT":      &c.Validation.Reject,
+		"CRAWL_INCLUDE_SPECIALS": &c.Crawl.IncludeSpecials,
+	}
+	for k, v := range boolVars {
+		if val, ok := os.LookupEnv(k); ok && val != "" {
+			parsed, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("config: env %s: %w", k, err)
+			}
+			*v = parsed
+		}
+	}
+
+	intVars := map[string]*int{
+		"MOVIE_MAX_ID":               &c.Crawl.MovieMaxID,
+		"SHOW_MAX_ID":                &c.Crawl.ShowMaxID,
+		"PERSON_MAX_ID":              &c.Crawl.PersonMaxID,
+		"CHART_PAGES":                &c.Charts.Pages,
+		"REFRESH_BUDGET":             &c.Refresh.Budget,
+		"TMDB_BREAKER_AUTH_FAILURES": &c.TMDB.BreakerAuthFailures,
+		"TMDB_BREAKER_ERRORS":        &c.TMDB.BreakerErrors,
+	}
+	for k, v := range intVars {
+		if val, ok := os.LookupEnv(k); ok && val != "" {
+			parsed, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("config: env %s: %w", k, err)
+			}
+			*v = parsed
+		}
+	}
+	return nil
+}
+
+// Validate reports every problem with the config at once.
+func (c Config) Validate() error {
+	var errs []error
+	if c.DBURL == "" {
+		errs = append(errs, errors.New("db_url is required (DB_URL, -db-url)"))
+	} else if _, err := pq.NewConnector(c.DBURL); err != nil {
+		// The parse error may quote the password, so it isn't passed on.
+		errs = append(errs, errors.New("db_url is neither a postgres url nor a key=value connection string"))
+	}
+	if c.TMDB.AccessToken == "" {
+		errs = append(errs, errors.New("tmdb.access_token is required (TMDB_AT, -tmdb-at)"))
+	}
+	if u, err := url.Parse(c.TMDB.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("tmdb.base_url %q must be an absolute http(s) url", c.TMDB.BaseURL))
+	}
+	if u, err := url.Parse(c.IMDb.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("imdb.url %q must be an absolute http(s) url", c.IMDb.URL))
+	}
+	if c.ListenAddr == "" {
+		errs = append(errs, errors.New("listen_addr must not be empty"))
+	}
+	if c.DataDir == "" {
+		errs = append(errs, errors.New("data_dir must not be empty"))
+	}
+	if c.TMDB.RateDelay < 0 {
+		errs = append(errs, errors.New("tmdb.rate_delay must not be negative"))
+	}
+	if c.TMDB.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("tmdb.request_timeout must be positive"))
+	}
+	if c.TMDB.BreakerAuthFailures <= 0 {
+		errs = append(errs, errors.New("tmdb.breaker_auth_failures must be positive"))
+	}
+	if c.TMDB.BreakerErrors <= 0 {
+		errs = append(errs, errors.New("tmdb.breaker_errors must be positive"))
+	}
+	if c.TMDB.BreakerProbeInterval <= 0 {
+		errs = append(errs, errors.New("tmdb.breaker_probe_interval must be positive"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+	if c.IMDb.Interval <= 0 {
+		errs = append(errs, errors.New("imdb.interval must be positive"))
+	}
+	if c.Reference.Interval <= 0 {
+		errs = append(errs, errors.New("reference.interval must be positive"))
+	}
+	if c.Charts.Interval <= 0 {
+		errs = append(errs, errors.New("charts.interval must be positive"))
+	}
+	if c.Refresh.Interval <= 0 {
+		errs = append(errs, errors.New("refresh.interval must be positive"))
+	}
+	if c.Refresh.Budget <= 0 {
+		errs = append(errs, errors.New("refresh.budget must be positive"))
+	}
+	if c.Refresh.MinAge < 0 {
+		errs = append(errs, errors.New("refresh.min_age must not be negative"))
+	}
+	if c.Charts.Pages <= 0 || c.Charts.Pages > MaxChartPages {
+		errs = append(errs, fmt.Errorf("charts.pages must be between 1 and %d", MaxChartPages))
+	}
+	if c.Crawl.MovieMaxID <= 0 {
+		errs = append(errs, errors.New("crawl.movie_max_id must be positive"))
+	}
+	if c.Crawl.ShowMaxID <= 0 {
+		errs = append(errs, errors.New("crawl.show_max_id must be positive"))
+	}
+	if c.Crawl.PersonMaxID <= 0 {
+		errs = append(errs, errors.New("crawl.person_max_id must be positive"))
+	}
+	if c.Crawl.CollectionTTL < 0 {
+		errs = append(errs, errors.New("crawl.collection_ttl must not be negative"))
+	}
+	for _, l := range c.Crawl.Languages {
+		if !validLanguageTag(l) {
+			errs = append(errs, fmt.Errorf("crawl.languages: %q is not a language tag like de or de-DE", l))
+		}
+	}
+	for _, r := range c.Validation.Rules {
+		if !slices.Contains(ValidationRules, r) {
+			errs = append(errs, fmt.Errorf("validation.rules: %q is not one of %s", r, strings.Join(ValidationRules, ", ")))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid config:\n%w", errors.Join(errs...))
+}
+
+// Redacted returns a copy that is safe to print.
+func (c Config) Redacted() Config {
+	if c.TMDB.AccessToken != "" {
+		c.TMDB.AccessToken = "REDACTED"
+	}
+	c.DBURL = redactDSN(c.DBURL)
+	return c
+}
+
+// dsnPassword finds the password of a key=value connection string, quoted
+// or not.
+var dsnPassword = regexp.MustCompile(`(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactDSN hides the password of a postgres URL, in the user info or the
+// password parameter, or of a lib/pq key=value connection string.
+func redactDSN(dsn string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsnPassword.ReplaceAllString(dsn, "${1}REDACTED")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		// Can't tell where the password is, hide everything.
+		return "REDACTED"
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "REDACTED")
+		}
+	}
+	q := u.Query()
+	if q.Has("password") {
+		q.Set("password", "REDACTED")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+func (c Config) Write(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedacted(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		want string
+	}{
+		{"url user info", "postgres://pg:hunter2@db:5432/tmdb?sslmode=disable", "postgres://pg:REDACTED@db:5432/tmdb?sslmode=disable"},
+		{"url query", "postgres://pg@db/tmdb?password=hunter2&sslmode=disable", "postgres://pg@db/tmdb?password=REDACTED&sslmode=disable"},
+		{"url without password", "postgres://pg@db/tmdb", "postgres://pg@db/tmdb"},
+		{"key value", "host=db user=pg password=hunter2 dbname=tmdb", "host=db user=pg password=REDACTED dbname=tmdb"},
+		{"key value spaced", "host=db password = hunter2", "host=db password = REDACTED"},
+		{"key value quoted", `host=db password='hunter 2\'s' dbname=tmdb`, "host=db password=REDACTED dbname=tmdb"},
+		{"key value without password", "host=db user=pg", "host=db user=pg"},
+		{"unparsable url", "postgres://pg:hunter2@db:port/tmdb", "REDACTED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{DBURL: tt.dsn}
+			cfg.TMDB.AccessToken = "secret-token"
+
+			got := cfg.Redacted()
+			if got.DBURL != tt.want {
+				t.Errorf("DBURL = %q, want %q", got.DBURL, tt.want)
+			}
+			if strings.Contains(got.DBURL, "hunter") {
+				t.Errorf("DBURL %q still holds the password", got.DBURL)
+			}
+			if got.TMDB.AccessToken != "REDACTED" {
+				t.Errorf("AccessToken = %q, want REDACTED", got.TMDB.AccessToken)
+			}
+		})
+	}
+}
+
+func TestValidateDBURL(t *testing.T) {
+	tests := []struct {
+		name  string
+		dsn   string
+		valid bool
+	}{
+		{"url", "postgres://pg:pg@localhost:5555/tmdb?sslmode=disable", true},
+		{"key value", "host=localhost port=5555 user=pg password=pg dbname=tmdb", true},
+		{"key value quoted", `host=localhost password='p g' dbname=tmdb`, true},
+		{"key value without value", "host=localhost dbname", false},
+		{"key value unterminated quote", "host=localhost password='pg", false},
+		{"url with bad port", "postgres://pg:pg@localhost:port/tmdb", false},
+		{"plain word", "tmdb", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.DBURL = tt.dsn
+			cfg.TMDB.AccessToken = "token"
+			err := cfg.Validate()
+			if got := err == nil || !strings.Contains(err.Error(), "db_url"); got != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// crawlPageSize is how many ids a crawl hands out at once.
+const crawlPageSize = 1000
+
+// crawl is the loop every crawler runs over its ids: ids that are stored
+// or known not to exist are skipped, the rest fetched, and the checkpoint
+// moved past every id handled.
+type crawl struct {
+	repo      *Repo
+	tp        string
+	overwrite bool
+	// exists reports whether v is stored already.
+	exists func(ctx context.Context, v int) (bool, error)
+	fetch  func(ctx context.Context, v int) error
+	// checkpoint stores the progress, nil for crawls that keep none.
+	checkpoint func(ctx context.Context, v int) error
+}
+
+// storeContext is what the results of a fetch are written with. Once an
+// item has been fetched it is stored, and its checkpoint moved, even if the
+// job is stopped in the meantime.
+func storeContext(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+// fetchListed fetches one id of a list a job works through. Fetch records
+// a failed item in not_found or failed itself, so a failure only ends the
+// job when ctx was cancelled, and err is then ctx's. stored reports
+// whether the item was written.
+func fetchListed(ctx context.Context, fetch func(ctx context.Context, v int, opts FetchOptions) error, v int, opts FetchOptions) (stored bool, err error) {
+	if fetch(ctx, v, opts) != nil {
+		return false, ctx.Err()
+	}
+	return true, nil
+}
+
+// idPages returns the next ids of a crawl greater than after, lowest
+// first, and none once the crawl is done.
+type idPages func(ctx context.Context, after int) ([]int, error)
+
+// rangePages visits every number up to end.
+func rangePages(end int) idPages {
+	return func(_ context.Context, after int) ([]int, error) {
+		var ids []int
+		for v := after + 1; v <= end && len(ids) < crawlPageSize; v++ {
+			ids = append(ids, v)
+		}
+		return ids, nil
+	}
+}
+
+// listPages visits sorted ids that were loaded up front.
+func listPages(ids []int) idPages {
+	return func(_ context.Context, after int) ([]int, error) {
+		i, _ := slices.BinarySearch(ids, after+1)
+		return ids[i:min(i+crawlPageSize, len(ids))], nil
+	}
+}
+
+// run crawls the ids pages hands out after after until they run out or ctx
+// is cancelled.
+func (c crawl) run(ctx context.Context, after int, pages idPages) error {
+	storeCtx := storeContext(ctx)
+	for {
+		ids, err := pages(ctx, after)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		for _, v := range ids {
+			if c.item(ctx, storeCtx, v) {
+				return nil
+			}
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+// item handles a single id and reports whether the crawl was stopped.
+func (c crawl) item(ctx context.Context, storeCtx context.Context, v int) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+
+	if !c.overwrite {
+		exists, err := c.exists(ctx, v)
+		if err != nil {
+			fmt.Println("Error getting item exists", err)
+		}
+		if exists {
+			fmt.Println("Skipping item since its found")
+			c.progress(ctx, v)
+			return false
+		}
+	}
+
+	exists, err := c.repo.NotFoundExists(ctx, c.tp, v)
+	if err != nil {
+		fmt.Println("Error getting not found", err)
+	}
+	if exists {
+		fmt.Println("Skipping item since it does not exists")
+		c.progress(ctx, v)
+		return false
+	}
+
+	err = c.fetch(ctx, v)
+	if err != nil {
+		// Stopped mid request, the item is retried on the next run.
+		return ctx.Err() != nil
+	}
+	c.progress(storeCtx, v)
+	fmt.Println(c.tp, "details stored for", v)
+	return false
+}
+
+func (c crawl) progress(ctx context.Context, v int) {
+	if c.checkpoint == nil {
+		return
+	}
+	if err := c.checkpoint(ctx, v); err != nil {
+		fmt.Println("Error storing", c.tp, "progress", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// collectPages walks pages the way crawl.run does.
+func collectPages(t *testing.T, pages idPages, after int) [][]int {
+	t.Helper()
+	var res [][]int
+	for {
+		ids, err := pages(context.Background(), after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) == 0 {
+			return res
+		}
+		res = append(res, ids)
+		after = ids[len(ids)-1]
+	}
+}
+
+func TestRangePages(t *testing.T) {
+	got := collectPages(t, rangePages(crawlPageSize+2), 0)
+	if len(got) != 2 || len(got[0]) != crawlPageSize || !reflect.DeepEqual(got[1], []int{crawlPageSize + 1, crawlPageSize + 2}) {
+		t.Errorf("rangePages() = %d pages ending in %v", len(got), got[len(got)-1])
+	}
+	if got[0][0] != 1 {
+		t.Errorf("first id = %d, want 1", got[0][0])
+	}
+
+	if got := collectPages(t, rangePages(10), 10); got != nil {
+		t.Errorf("rangePages() past end = %v, want nothing", got)
+	}
+}
+
+func TestListPages(t *testing.T) {
+	ids := make([]int, crawlPageSize+1)
+	for i := range ids {
+		ids[i] = (i + 1) * 3
+	}
+
+	tests := []struct {
+		name  string
+		after int
+		want  [][]int
+	}{
+		{"from start", 0, [][]int{ids[:crawlPageSize], ids[crawlPageSize:]}},
+		{"after an id", 3, [][]int{ids[1 : crawlPageSize+1]}},
+		{"between ids", 4, [][]int{ids[1 : crawlPageSize+1]}},
+		{"past the last", ids[len(ids)-1], nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collectPages(t, listPages(ids), tt.after)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listPages() = %d pages, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
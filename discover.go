@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxDiscoverPages is the deepest page /discover serves. Queries with more
+// results are split by date range until every part fits.
+const MaxDiscoverPages = 500
+
+// earliestRelease is where splitting a date range open towards the past
+// starts from, nothing on TMDB is older.
+var earliestRelease = time.Date(1870, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// latestRelease is where splitting a date range open towards the future
+// starts from.
+func latestRelease(now time.Time) time.Time {
+	return time.Date(now.Year()+5, 12, 31, 0, 0, 0, 0, time.UTC)
+}
+
+// languageCode is what with_original_language takes, an ISO 639-1 code
+// without a country.
+var languageCode = regexp.MustCompile(`^[a-z]{2}$`)
+
+// DiscoverSpec is the filter of a discover job.
+type DiscoverSpec struct {
+	// Type is movie or show.
+	Type   string `json:"type"`
+	Genres []int  `json:"genres"`
+	// YearFrom and YearTo bound the release or first air date, inclusive.
+	YearFrom int `json:"year_from"`
+	YearTo   int `json:"year_to"`
+	// Language is the original language, like ko.
+	Language string `json:"language"`
+	// Region is the release region of movies and the origin country of
+	// shows, like KR.
+	Region         string  `json:"region"`
+	VoteAverageGte float64 `json:"vote_average_gte"`
+	VoteCountGte   int     `json:"vote_count_gte"`
+	// Sort is a TMDB sort_by value, popularity.desc by default.
+	Sort string `json:"sort"`
+}
+
+func (s DiscoverSpec) Validate() error {
+	if s.Type != "movie" && s.Type != "show" {
+		return fmt.Errorf("discover type must be movie or show")
+	}
+	if s.YearFrom != 0 && s.YearTo != 0 && s.YearFrom > s.YearTo {
+		return fmt.Errorf("year_from must not be after year_to")
+	}
+	if s.Language != "" && !languageCode.MatchString(s.Language) {
+		return fmt.Errorf("%q is not an ISO 639-1 language code like ko", s.Language)
+	}
+	if s.Region != "" && len(s.Region) != 2 {
+		return fmt.Errorf("region must be a country code like KR")
+	}
+	return nil
+}
+
+// dateField is the date the query is narrowed and split by.
+func (s DiscoverSpec) dateField() string {
+	if s.Type == "show" {
+		return "first_air_date"
+	}
+	return "primary_release_date"
+}
+
+// tmdbType is the discover endpoint of the spec.
+func (s DiscoverSpec) tmdbType() string {
+	if s.Type == "show" {
+		return "tv"
+	}
+	return "movie"
+}
+
+// params maps the spec onto the discover query parameters, except for the
+// date range.
+func (s DiscoverSpec) params() url.Values {
+	q := url.Values{}
+	if len(s.Genres) > 0 {
+		genres := make([]string, 0, len(s.Genres))
+		for _, g := range s.Genres {
+			genres = append(genres, strconv.Itoa(g))
+		}
+		q.Set("with_genres", strings.Join(genres, ","))
+	}
+	if s.Language != "" {
+		q.Set("with_original_language", s.Language)
+	}
+	if s.Region != "" {
+		if s.Type == "show" {
+			q.Set("with_origin_country", strings.ToUpper(s.Region))
+		} else {
+			q.Set("region", strings.ToUpper(s.Region))
+		}
+	}
+	if s.VoteAverageGte > 0 {
+		q.Set("vote_average.gte", strconv.FormatFloat(s.VoteAverageGte, 'f', -1, 64))
+	}
+	if s.VoteCountGte > 0 {
+		q.Set("vote_count.gte", strconv.Itoa(s.VoteCountGte))
+	}
+	sort := s.Sort
+	if sort == "" {
+		sort = "popularity.desc"
+	}
+	q.Set("sort_by", sort)
+	return q
+}
+
+// dateRange is the inclusive range of the spec's years, a zero time on the
+// sides it leaves open. Any bound drops the titles that have no date yet.
+func (s DiscoverSpec) dateRange() (time.Time, time.Time) {
+	var from, to time.Time
+	if s.YearFrom != 0 {
+		from = time.Date(s.YearFrom, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if s.YearTo != 0 {
+		to = time.Date(s.YearTo, 12, 31, 0, 0, 0, 0, time.UTC)
+	}
+	return from, to
+}
+
+// Discoverer crawls the movies or shows matched by a DiscoverSpec.
+type Discoverer struct {
+	usecase *Usecase
+	at      string
+	repo    *Repo
+	movieC  *MovieCrwaler
+	showC   *ShowCrwaler
+}
+
+func NewDiscoverer(usecase *Usecase, at string, repo *Repo, movieC *MovieCrwaler, showC *ShowCrwaler) *Discoverer {
+	return &Discoverer{
+		usecase: usecase,
+		at:      at,
+		repo:    repo,
+		movieC:  movieC,
+		showC:   showC,
+	}
+}
+
+// Start fetches every item the spec matches. Stored items are skipped
+// unless overwrite is set.
+func (d *Discoverer) Start(ctx context.Context, spec DiscoverSpec, overwrite bool, opts FetchOptions) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	fetch := d.movieC.Fetch
+	if spec.Type == "show" {
+		fetch = d.showC.Fetch
+	}
+
+	seen := make(map[int64]bool)
+	crawl := func(id int64) error {
+		if seen[id] {
+			return nil
+		}
+		seen[id] = true
+		v := int(id)
+
+		if !overwrite {
+			exists, err := d.repo.ItemExists(ctx, spec.Type, v)
+			if err != nil {
+				fmt.Println("Error getting item exists", err)
+			}
+			if exists {
+				return nil
+			}
+		}
+		stored, err := fetchListed(ctx, fetch, v, opts)
+		if stored {
+			fmt.Println("Discovered", spec.Type, "stored for", v)
+		}
+		return err
+	}
+
+	from, to := spec.dateRange()
+	err := d.crawlRange(ctx, spec, from, to, crawl)
+	if ctx.Err() != nil {
+		return nil
+	}
+	fmt.Printf("Discover crawl done, %d %ss matched\n", len(seen), spec.Type)
+	return err
+}
+
+// crawlRange pages through the results dated between from and to, a zero
+// time leaving that side open. If they don't fit in MaxDiscoverPages the
+// range is halved instead. The halves keep the open sides, so no dated
+// title is lost, but titles without a date only turn up in a query
+// without any bound. TMDB can't filter for them, so once such a query is
+// split it is also crawled as deep as it goes.
+func (d *Discoverer) crawlRange(ctx context.Context, spec DiscoverSpec, from time.Time, to time.Time, crawl func(id int64) error) error {
+	params := spec.params()
+	if !from.IsZero() {
+		params.Set(spec.dateField()+".gte", from.Format(time.DateOnly))
+	}
+	if !to.IsZero() {
+		params.Set(spec.dateField()+".lte", to.Format(time.DateOnly))
+	}
+
+	first, err := d.usecase.Discover(ctx, spec.tmdbType(), params, 1, d.at)
+	if err != nil {
+		return err
+	}
+
+	lo, hi := from, to
+	if lo.IsZero() {
+		lo = earliestRelease
+	}
+	if hi.IsZero() {
+		hi = latestRelease(time.Now().UTC())
+	}
+	if first.TotalPages > MaxDiscoverPages && hi.After(lo) {
+		days := int(hi.Sub(lo).Hours() / 24)
+		mid := lo.AddDate(0, 0, days/2)
+		fmt.Printf("Splitting discover range %s..%s with %d pages\n", dateBound(from), dateBound(to), first.TotalPages)
+		if err := d.crawlRange(ctx, spec, from, mid, crawl); err != nil {
+			return err
+		}
+		if err := d.crawlRange(ctx, spec, mid.AddDate(0, 0, 1), to, crawl); err != nil {
+			return err
+		}
+		if !from.IsZero() || !to.IsZero() {
+			return nil
+		}
+		fmt.Println("Crawling the unbounded", spec.Type, "results for titles without a date")
+	} else if first.TotalPages > MaxDiscoverPages {
+		fmt.Printf("Range %s..%s has %d pages, crawling the first %d\n", dateBound(from), dateBound(to), first.TotalPages, MaxDiscoverPages)
+	}
+
+	pages := min(int(first.TotalPages), MaxDiscoverPages)
+	page := first
+	for p := 1; ; p++ {
+		for _, e := range page.Results {
+			if err := crawl(e.ID); err != nil {
+				return err
+			}
+		}
+		if p >= pages {
+			return nil
+		}
+		page, err = d.usecase.Discover(ctx, spec.tmdbType(), params, p+1, d.at)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// dateBound prints a side of a date range, open ones as "".
+func dateBound(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateOnly)
+}
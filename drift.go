@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DriftIssue is a difference between a TMDB payload and the model it is
+// decoded into. Kind is unknown_key, type_mismatch or invalid_value, a value
+// of the right JSON type its model type still rejects, like a date that
+// doesn't parse.
+type DriftIssue struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	JSONType string `json:"json_type"`
+	Expected string `json:"expected,omitempty"`
+}
+
+var unmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// malformedValue is implemented by types that take a bad value without
+// failing the item, like models.Date, and tell about it.
+type malformedValue interface {
+	Malformed() string
+}
+
+// detectDrift compares a payload against the json tags of t and returns
+// the keys t has no field for and the values whose JSON type t can't hold.
+// Each path is reported once, array elements share the path "x[]".
+func detectDrift(body []byte, t reflect.Type) ([]DriftIssue, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]DriftIssue)
+	walkDrift(v, t, "", found)
+
+	res := make([]DriftIssue, 0, len(found))
+	for _, issue := range found {
+		res = append(res, issue)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res, nil
+}
+
+func walkDrift(v any, t reflect.Type, path string, found map[string]DriftIssue) {
+	if v == nil {
+		// null fits anything, it just means the zero value.
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface {
+		return
+	}
+
+	mismatch := func() {
+		found[path+"|type_mismatch"] = DriftIssue{
+			Path:     path,
+			Kind:     "type_mismatch",
+			JSONType: jsonType(v),
+			Expected: t.String(),
+		}
+	}
+
+	// Types with their own decoding, like models.Date, are asked whether
+	// they take the value.
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		if !unmarshals(v, t) {
+			found[path+"|invalid_value"] = DriftIssue{
+				Path:     path,
+				Kind:     "invalid_value",
+				JSONType: jsonType(v),
+				Expected: t.String(),
+			}
+		}
+		return
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := jsonFields(t)
+			for key, child := range val {
+				ft, ok := fields[key]
+				childPath := joinPath(path, key)
+				if !ok {
+					found[childPath+"|unknown_key"] = DriftIssue{
+						Path:     childPath,
+						Kind:     "unknown_key",
+						JSONType: jsonType(child),
+					}
+					continue
+				}
+				walkDrift(child, ft, childPath, found)
+			}
+		case reflect.Map:
+			for _, child := range val {
+				walkDrift(child, t.Elem(), joinPath(path, "*"), found)
+			}
+		default:
+			mismatch()
+		}
+	case []any:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			mismatch()
+			return
+		}
+		for _, child := range val {
+			walkDrift(child, t.Elem(), path+"[]", found)
+		}
+	case string:
+		if t.Kind() != reflect.String {
+			mismatch()
+		}
+	case bool:
+		if t.Kind() != reflect.Bool {
+			mismatch()
+		}
+	case json.Number:
+		switch t.Kind() {
+		case reflect.Float32, reflect.Float64:
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if _, err := val.Int64(); err != nil {
+				mismatch()
+			}
+		default:
+			mismatch()
+		}
+	}
+}
+
+// unmarshals reports whether t decodes v without an error and without
+// calling it malformed.
+func unmarshals(v any, t reflect.Type) bool {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	p := reflect.New(t).Interface()
+	if err := p.(json.Unmarshaler).UnmarshalJSON(raw); err != nil {
+		return false
+	}
+	if m, ok := p.(malformedValue); ok && m.Malformed() != "" {
+		return false
+	}
+	return true
+}
+
+// jsonFields maps the JSON keys of a struct to their field types, the
+// fields of embedded structs included.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	res := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range jsonFields(ft) {
+					res[k] = v
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		res[name] = f.Type
+	}
+	return res
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case json.Number:
+		return "number"
+	}
+	return "null"
+}
+
+// checkDrift records how body differs from the model it is decoded into
+// when drift detection is on. It never fails the request.
+func (u *Usecase) checkDrift(ctx context.Context, endpoint string, body []byte, model any) {
+	if u.drift == nil {
+		return
+	}
+	issues, err := detectDrift(body, reflect.TypeOf(model))
+	if err != nil || len(issues) == 0 {
+		return
+	}
+	err = u.drift.StoreDrift(context.WithoutCancel(ctx), endpoint, issues)
+	if err != nil {
+		fmt.Println("Error storing drift of", endpoint, err)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"tmdb_scraper/models"
+)
+
+func TestDetectDriftUnmarshalers(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []DriftIssue
+	}{
+		{"date", `{"air_date": "2011-04-17"}`, []DriftIssue{}},
+		{"blank date", `{"air_date": ""}`, []DriftIssue{}},
+		{"null date", `{"air_date": null}`, []DriftIssue{}},
+		{"malformed date", `{"air_date": "soon"}`, []DriftIssue{
+			{Path: "air_date", Kind: "invalid_value", JSONType: "string", Expected: "models.Date"},
+		}},
+		{"date as number", `{"air_date": 20110417}`, []DriftIssue{
+			{Path: "air_date", Kind: "invalid_value", JSONType: "number", Expected: "models.Date"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectDrift([]byte(tt.body), reflect.TypeFor[models.Episode]())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectDrift() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// writeICal writes the entries as an iCalendar (RFC 5545) feed with one
+// all day event per episode.
+func writeICal(w io.Writer, entries []CalendarEntry, now time.Time) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//tmdb_scraper//calendar//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:TV calendar",
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, e := range entries {
+		summary := fmt.Sprintf("%s S%02dE%02d", e.ShowName, e.SeasonNumber, e.EpisodeNumber)
+		if e.Name != "" {
+			summary += " " + e.Name
+		}
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:show-%d-s%d-e%d@tmdb_scraper", e.ShowID, e.SeasonNumber, e.EpisodeNumber),
+			"DTSTAMP:"+stamp,
+			"DTSTART;VALUE=DATE:"+e.AirDate.Format("20060102"),
+			"DTEND;VALUE=DATE:"+e.AirDate.AddDate(0, 0, 1).Format("20060102"),
+			"SUMMARY:"+icalEscape(summary),
+		)
+		if e.Overview != "" {
+			lines = append(lines, "DESCRIPTION:"+icalEscape(e.Overview))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, l := range lines {
+		if _, err := io.WriteString(w, icalFold(l)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var icalEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func icalEscape(s string) string {
+	return icalEscaper.Replace(s)
+}
+
+// icalFold splits content lines longer than 75 octets, continuation lines
+// start with a space. It never splits inside a UTF-8 sequence.
+func icalFold(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			// The leading space counts towards the next line.
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
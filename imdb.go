@@ -24,14 +24,18 @@ const (
 )
 
 type IMDBImporter struct {
-	DB      *sql.DB
-	DataDir string
+	DB       *sql.DB
+	DataDir  string
+	URL      string
+	Interval time.Duration
 }
 
-func NewIMDbImporter(db *sql.DB, dataDir string) *IMDBImporter {
+func NewIMDbImporter(db *sql.DB, dataDir string, url string, interval time.Duration) *IMDBImporter {
 	return &IMDBImporter{
-		DB:      db,
-		DataDir: dataDir,
+		DB:       db,
+		DataDir:  dataDir,
+		URL:      url,
+		Interval: interval,
 	}
 }
 
@@ -43,11 +47,15 @@ func (i *IMDBImporter) Start(ctx context.Context) error {
 
 	log.Println("Starting initial sync...")
 	if err := i.runSync(ctx); err != nil {
+		if ctx.Err() != nil {
+			log.Println("Initial sync cancelled, transaction rolled back")
+			return nil
+		}
 		log.Printf("Initial sync failed: %v", err)
 		return err
 	}
 
-	ticker := time.NewTicker(UpdateInterval)
+	ticker := time.NewTicker(i.Interval)
 	defer ticker.Stop()
 
 	for {
@@ -55,6 +63,10 @@ func (i *IMDBImporter) Start(ctx context.Context) error {
 		case <-ticker.C:
 			log.Println("Starting scheduled sync...")
 			if err := i.runSync(ctx); err != nil {
+				if ctx.Err() != nil {
+					log.Println("Scheduled sync cancelled, transaction rolled back")
+					return nil
+				}
 				log.Printf("Scheduled sync failed: %v", err)
 				return err
 			}
@@ -64,6 +76,15 @@ func (i *IMDBImporter) Start(ctx context.Context) error {
 	}
 }
 
+// SyncOnce runs a single download and import without scheduling further
+// syncs.
+func (i *IMDBImporter) SyncOnce(ctx context.Context) error {
+	if err := os.MkdirAll(i.DataDir, 0755); err != nil {
+		return err
+	}
+	return i.runSync(ctx)
+}
+
 // runSync orchestrates the download and database update
 func (i *IMDBImporter) runSync(ctx context.Context) error {
 	gzPath := filepath.Join(i.DataDir, "title.ratings.tsv.gz")
@@ -75,7 +96,7 @@ func (i *IMDBImporter) runSync(ctx context.Context) error {
 	defer os.Remove(gzPath) // Cleanup zip file after processing
 
 	// 2. Initialize Schema
-	if err := i.initSchema(); err != nil {
+	if err := i.initSchema(ctx); err != nil {
 		return fmt.Errorf("schema init error: %w", err)
 	}
 
@@ -96,11 +117,10 @@ func (i *IMDBImporter) downloadFile(ctx context.Context, destPath string) error
 	}
 	defer out.Close()
 
-	req, err := http.NewRequest(http.MethodGet, ImdbURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, i.URL, nil)
 	if err != nil {
 		return err
 	}
-	req = req.WithContext(ctx)
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
@@ -115,14 +135,14 @@ func (i *IMDBImporter) downloadFile(ctx context.Context, destPath string) error
 	return err
 }
 
-func (i *IMDBImporter) initSchema() error {
+func (i *IMDBImporter) initSchema(ctx context.Context) error {
 	query := `
 	CREATE TABLE IF NOT EXISTS imdb_ratings (
 		tconst VARCHAR(15) PRIMARY KEY,
 		average_rating FLOAT,
 		num_votes INTEGER
 	);`
-	_, err := i.DB.Exec(query)
+	_, err := i.DB.ExecContext(ctx, query)
 	return err
 }
 
@@ -162,7 +182,7 @@ func (i *IMDBImporter) processAndInsert(ctx context.Context, gzPath string) erro
 	defer txn.Rollback()
 
 	// 1. Create Temp Table (Drop on Commit ensures it cleans up)
-	_, err = txn.Exec(`
+	_, err = txn.ExecContext(ctx, `
 		CREATE TEMP TABLE temp_ratings (
 			tconst VARCHAR(15),
 			average_rating FLOAT,
@@ -188,7 +208,9 @@ outer:
 	for {
 		select {
 		case <-ctx.Done():
-			return nil
+			// Returning an error makes the deferred Rollback discard the
+			// partially streamed rows.
+			return ctx.Err()
 		default:
 			record, err := reader.Read()
 			if err == io.EOF {
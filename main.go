@@ -1,187 +1,15 @@
 package main
 
 import (
-	"context"
-	"database/sql"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
+	"errors"
 	"net/http"
 	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 	"time"
-
-	_ "github.com/lib/pq"
 )
 
 func main() {
-	dsn := os.Getenv("DB_URL")
-	at := os.Getenv("TMDB_AT")
-	url := os.Getenv("TMDB_BASE_URL")
-	dataDir := os.Getenv("DATA_DIR")
-	// dsn = "postgres://pg:pg@192.168.1.50:5555/tmdb?sslmode=disable"
-	// dataDir = "./"
-	// url = "https://api.themoviedb.org/3"
-
-	log.Println("Connecting to db")
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := db.PingContext(ctx); err != nil {
-		log.Fatal(err)
-	}
-
-	log.Println("Connected to Postgres.")
-	client := NewClient()
-	uc := NewUsecase(url, client)
-
-	repo := NewRepo(db)
-	err = repo.CreateDb()
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
-
-	mc := NewMovieCrawler(
-		uc,
-		at,
-		repo,
-	)
-	sc := NewShowCrawler(
-		uc,
-		at,
-		repo,
-	)
-
-	imdbI := NewIMDbImporter(db, dataDir)
-
-	manager := NewScrapeManager(sc, mc, imdbI)
-
-	http.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
-		stats, err := manager.GetStats()
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(err.Error()))
-			return
-		}
-		body, _ := json.Marshal(stats)
-		w.WriteHeader(http.StatusOK)
-		w.Write(body)
-	})
-
-	http.HandleFunc("POST /start", func(w http.ResponseWriter, r *http.Request) {
-		type Input struct {
-			Tp        string `json:"type"`
-			Start     int    `json:"start"`
-			End       int    `json:"end"`
-			Overwrite bool   `json:"overwrite"`
-		}
-		var input Input
-		bodyBytes, err := io.ReadAll(r.Body)
-		if err != nil {
-			fmt.Println("Error reading body", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		defer r.Body.Close()
-
-		err = json.Unmarshal(bodyBytes, &input)
-		if err != nil {
-			fmt.Println("Error unmarshalling body", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if input.Tp != "movie" && input.Tp != "show" && input.Tp != "imdb" {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("Invalid type"))
-			return
-		}
-
-		if input.Tp == "movie" {
-			manager.StartMovieSync(input.Start, input.End, input.Overwrite)
-		}
-
-		if input.Tp == "show" {
-			manager.StartShowSync(input.Start, input.End, input.Overwrite)
-		}
-
-		if input.Tp == "imdb" {
-			manager.StartIMDBSync()
-		}
-
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Process started successfully"))
-	})
-
-	http.HandleFunc("POST /stop", func(w http.ResponseWriter, r *http.Request) {
-		type Input struct {
-			Tp        string `json:"type"`
-			Start     int    `json:"start"`
-			End       int    `json:"end"`
-			Overwrite bool   `json:"overwrite"`
-		}
-		var input Input
-		bodyBytes, err := io.ReadAll(r.Body)
-		if err != nil {
-			fmt.Println("Error reading body", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		defer r.Body.Close()
-
-		err = json.Unmarshal(bodyBytes, &input)
-		if err != nil {
-			fmt.Println("Error unmarshalling body", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if input.Tp != "movie" && input.Tp != "show" && input.Tp != "imdb" {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("Invalid type"))
-			return
-		}
-
-		if input.Tp == "movie" {
-			manager.StopMovieScrape()
-		}
-
-		if input.Tp == "show" {
-			manager.StopShowScrape()
-		}
-
-		if input.Tp == "imdb" {
-			manager.StopImdbScrape()
-		}
-
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Process stopped successfully"))
-	})
-
-	go func() {
-		fmt.Println("Starting http server")
-		err = http.ListenAndServe(":6996", nil)
-		if err != nil {
-			fmt.Println("Error starting http server", err)
-			return
-		}
-	}()
-
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-
-	<-sig
-
-	log.Println("Shutting down...")
-	manager.ShutDown()
-	db.Close()
+	os.Exit(runCommand(os.Args[1:]))
 }
 
 type Res struct {
@@ -189,18 +17,23 @@ type Res struct {
 	Err error
 }
 
+var ErrClientClosed = errors.New("http client is closed")
+
 type HttpClient struct {
 	timeSinceLast *time.Time
 	reqChan       chan *http.Request
 	client        *http.Client
 	active        map[*http.Request]chan *Res
 	mtx           *sync.Mutex
-	delay         int
+	delay         time.Duration
+	quit          chan struct{}
+	done          chan struct{}
+	closeOnce     *sync.Once
 }
 
-func NewClient() *HttpClient {
+func NewClient(delay time.Duration, timeout time.Duration) *HttpClient {
 	var tmdbClient = &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout: timeout,
 		Transport: &http.Transport{
 			MaxIdleConns:        100,
 			MaxIdleConnsPerHost: 20,
@@ -210,43 +43,83 @@ func NewClient() *HttpClient {
 		},
 	}
 	client := &HttpClient{
-		reqChan: make(chan *http.Request),
-		client:  tmdbClient,
-		active:  make(map[*http.Request]chan *Res),
-		mtx:     &sync.Mutex{},
-		delay:   200,
+		reqChan:   make(chan *http.Request),
+		client:    tmdbClient,
+		active:    make(map[*http.Request]chan *Res),
+		mtx:       &sync.Mutex{},
+		delay:     delay,
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+		closeOnce: &sync.Once{},
 	}
 	go client.Start()
 	return client
 }
 
+// Do queues req behind the rate limiter. If the request context is done
+// while it is still queued it is dropped without being sent.
 func (c *HttpClient) Do(req *http.Request) (*http.Response, error) {
-	cn := c.doInternal(req)
+	cn, err := c.doInternal(req)
+	if err != nil {
+		return nil, err
+	}
 	res := <-cn
 	return res.Res, res.Err
 }
 
-func (c *HttpClient) doInternal(req *http.Request) chan *Res {
+func (c *HttpClient) doInternal(req *http.Request) (chan *Res, error) {
+	// Buffered so sendReq never blocks on a caller that has gone away.
+	cn := make(chan *Res, 1)
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
-	cn := make(chan *Res)
 	c.active[req] = cn
+	c.mtx.Unlock()
+
+	select {
+	case c.reqChan <- req:
+		return cn, nil
+	case <-req.Context().Done():
+		c.remove(req)
+		return nil, req.Context().Err()
+	case <-c.quit:
+		c.remove(req)
+		return nil, ErrClientClosed
+	}
+}
 
-	c.reqChan <- req
-
+func (c *HttpClient) remove(req *http.Request) chan *Res {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	cn := c.active[req]
+	delete(c.active, req)
 	return cn
 }
 
 func (c *HttpClient) Start() {
-	for v := range c.reqChan {
-		v := v
+	defer close(c.done)
+	for {
+		var v *http.Request
+		select {
+		case v = <-c.reqChan:
+		case <-c.quit:
+			return
+		}
 		if c.timeSinceLast != nil {
 			nowTime := time.Now()
-			sinceLastMilliseconds := nowTime.Sub(*c.timeSinceLast).Milliseconds()
-			if sinceLastMilliseconds < int64(c.delay) {
-				time.Sleep(time.Duration(int64(c.delay)-sinceLastMilliseconds) * time.Millisecond)
+			sinceLast := nowTime.Sub(*c.timeSinceLast)
+			if sinceLast < c.delay {
+				wait := time.NewTimer(c.delay - sinceLast)
+				select {
+				case <-wait.C:
+				case <-v.Context().Done():
+					wait.Stop()
+				}
 			}
 		}
+		// Cancelled while waiting for its slot, don't spend the slot on it.
+		if err := v.Context().Err(); err != nil {
+			c.remove(v) <- &Res{Err: err}
+			continue
+		}
 		tm := time.Now()
 		c.timeSinceLast = &tm
 		go c.sendReq(v)
@@ -255,10 +128,18 @@ func (c *HttpClient) Start() {
 
 func (c *HttpClient) sendReq(req *http.Request) {
 	res, err := c.client.Do(req)
-	c.mtx.Lock()
-	defer c.mtx.Unlock()
-	c.active[req] <- &Res{
+	c.remove(req) <- &Res{
 		Err: err,
 		Res: res,
 	}
 }
+
+// Close stops the dispatcher. Requests already sent keep running to
+// completion, new ones fail with ErrClientClosed.
+func (c *HttpClient) Close() {
+	c.closeOnce.Do(func() {
+		close(c.quit)
+	})
+	<-c.done
+	c.client.CloseIdleConnections()
+}
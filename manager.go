@@ -2,175 +2,466 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
 	"time"
 )
 
 type ScrapeStats struct {
-	ShowCrawling         bool       `json:"show_crawling"`
-	MovieCrawling        bool       `json:"movie_crawling"`
-	IMDBWorking          bool       `json:"imdb_working"`
-	MovieProgress        int        `json:"movie_progress"`
-	ShowProgress         int        `json:"show_progress"`
-	LastMovieCrwalerTime *time.Time `json:"last_movie_crwaler_time,omitempty"`
-	LastShowCrwalerTime  *time.Time `json:"last_show_crwaler_time,omitempty"`
-	LastIMDBSyncTime     *time.Time `json:"last_imdb_sync_time,omitempty"`
+	ShowCrawling     bool `json:"show_crawling"`
+	MovieCrawling    bool `json:"movie_crawling"`
+	PersonCrawling   bool `json:"person_crawling"`
+	CompanyCrawling  bool `json:"company_crawling"`
+	NetworkCrawling  bool `json:"network_crawling"`
+	IMDBWorking      bool `json:"imdb_working"`
+	ReferenceWorking bool `json:"reference_working"`
+	ChartsWorking    bool `json:"charts_working"`
+	DiscoverWorking  bool `json:"discover_working"`
+	StaleWorking     bool `json:"stale_working"`
+	MovieProgress    int  `json:"movie_progress"`
+	ShowProgress     int  `json:"show_progress"`
+	PersonProgress   int  `json:"person_progress"`
+	// ReferencedPersonProgress is the checkpoint of the referenced person
+	// crawl.
+	ReferencedPersonProgress int        `json:"referenced_person_progress"`
+	LastMovieCrwalerTime     *time.Time `json:"last_movie_crwaler_time,omitempty"`
+	LastShowCrwalerTime      *time.Time `json:"last_show_crwaler_time,omitempty"`
+	LastPersonCrawlerTime    *time.Time `json:"last_person_crawler_time,omitempty"`
+	LastIMDBSyncTime         *time.Time `json:"last_imdb_sync_time,omitempty"`
+	LastReferenceSyncTime    *time.Time `json:"last_reference_sync_time,omitempty"`
+	LastChartsSyncTime       *time.Time `json:"last_charts_sync_time,omitempty"`
+	// Breaker is open while the jobs are paused because TMDB keeps
+	// failing.
+	Breaker BreakerState `json:"breaker"`
+}
+
+// JobStatus is one job as listed by GET /jobs.
+type JobStatus struct {
+	Name    string     `json:"name"`
+	Working bool       `json:"working"`
+	Started *time.Time `json:"started,omitempty"`
+	// Paused is a working job waiting for the circuit breaker to close,
+	// PauseReason says why it opened.
+	Paused      bool   `json:"paused"`
+	PauseReason string `json:"pause_reason,omitempty"`
+}
+
+// FetchOptions tune what is fetched for every item of a job.
+type FetchOptions struct {
+	// Deep fetches every episode of a show on its own, with its credits,
+	// guest stars, images and external ids.
+	Deep bool `json:"deep"`
+	// Languages limits the stored translations to these "de" or "de-DE"
+	// style tags. Empty falls back to the configured crawl languages.
+	Languages []string `json:"languages"`
+}
+
+// job is the bookkeeping for one kind of background work. At most one job
+// of each kind runs at a time.
+type job struct {
+	cancel  context.CancelFunc
+	working bool
+	time    *time.Time
 }
 
 type ScrapeManager struct {
-	showC        *ShowCrwaler
-	movieC       *MovieCrwaler
-	imdbI        *IMDBImporter
-	showCancel   context.CancelFunc
-	movieCancel  context.CancelFunc
-	imdbCancel   context.CancelFunc
-	showWorking  bool
-	movieWorking bool
-	imdbWorking  bool
-	showTime     *time.Time
-	movieTime    *time.Time
-	imdbTime     *time.Time
+	showC   *ShowCrwaler
+	movieC  *MovieCrwaler
+	personC *PersonCrawler
+	orgC    *CompanyCrawler
+	imdbI   *IMDBImporter
+	refS    *ReferenceSyncer
+	chartS  *ChartSyncer
+	discS   *Discoverer
+	staleR  *StaleRefresher
+	repo    *Repo
+	breaker *CircuitBreaker
+	jobs    map[string]*job
+	mtx     *sync.Mutex
+	wg      *sync.WaitGroup
+	// closed refuses new jobs once ShutDown has been called.
+	closed bool
 }
 
 func NewScrapeManager(
 	showC *ShowCrwaler,
 	movieC *MovieCrwaler,
+	personC *PersonCrawler,
+	orgC *CompanyCrawler,
 	imdbI *IMDBImporter,
+	refS *ReferenceSyncer,
+	chartS *ChartSyncer,
+	discS *Discoverer,
+	staleR *StaleRefresher,
+	repo *Repo,
+	breaker *CircuitBreaker,
 ) *ScrapeManager {
 	return &ScrapeManager{
-		showC:  showC,
-		movieC: movieC,
-		imdbI:  imdbI,
+		showC:   showC,
+		movieC:  movieC,
+		personC: personC,
+		orgC:    orgC,
+		imdbI:   imdbI,
+		refS:    refS,
+		chartS:  chartS,
+		discS:   discS,
+		staleR:  staleR,
+		repo:    repo,
+		breaker: breaker,
+		jobs:    make(map[string]*job),
+		mtx:     &sync.Mutex{},
+		wg:      &sync.WaitGroup{},
 	}
 }
 
-func (m *ScrapeManager) GetStats() (ScrapeStats, error) {
+func (m *ScrapeManager) GetStats(ctx context.Context) (ScrapeStats, error) {
+	m.mtx.Lock()
 	res := ScrapeStats{
-		ShowCrawling:         m.showWorking,
-		MovieCrawling:        m.movieWorking,
-		IMDBWorking:          m.imdbWorking,
-		LastMovieCrwalerTime: m.movieTime,
-		LastIMDBSyncTime:     m.imdbTime,
-		LastShowCrwalerTime:  m.showTime,
+		ShowCrawling:          m.getJob("show").working,
+		MovieCrawling:         m.getJob("movie").working,
+		PersonCrawling:        m.getJob("person").working,
+		CompanyCrawling:       m.getJob("company").working,
+		NetworkCrawling:       m.getJob("network").working,
+		IMDBWorking:           m.getJob("imdb").working,
+		ReferenceWorking:      m.getJob("reference").working,
+		ChartsWorking:         m.getJob("charts").working,
+		DiscoverWorking:       m.getJob("discover").working,
+		StaleWorking:          m.getJob("stale").working,
+		LastMovieCrwalerTime:  m.getJob("movie").time,
+		LastIMDBSyncTime:      m.getJob("imdb").time,
+		LastShowCrwalerTime:   m.getJob("show").time,
+		LastPersonCrawlerTime: m.getJob("person").time,
+		LastReferenceSyncTime: m.getJob("reference").time,
+		LastChartsSyncTime:    m.getJob("charts").time,
+		Breaker:               m.breaker.State(),
 	}
+	m.mtx.Unlock()
 
-	index, err := m.movieC.GetMovieProgress()
+	index, err := m.movieC.GetMovieProgress(ctx)
 	if err != nil {
 		fmt.Println("Error getting movie progress", err)
 	}
 	res.MovieProgress = index
 
-	index, err = m.showC.GetShowProgress()
+	index, err = m.showC.GetShowProgress(ctx)
 	if err != nil {
-		fmt.Println("Error getting movie progress", err)
+		fmt.Println("Error getting show progress", err)
 	}
 	res.ShowProgress = index
 
+	index, err = m.personC.GetPersonProgress(ctx)
+	if err != nil {
+		fmt.Println("Error getting person progress", err)
+	}
+	res.PersonProgress = index
+
+	index, err = m.repo.GetReferencedPersonProgress(ctx)
+	if err != nil {
+		fmt.Println("Error getting referenced person progress", err)
+	}
+	res.ReferencedPersonProgress = index
+
 	return res, nil
 }
 
-func (m *ScrapeManager) StartMovieSync(start int, end int, overwrite bool) error {
-	if m.movieWorking {
-		return fmt.Errorf("Movie sync is currently in progress")
+// GetJobs lists every job that was started since the process came up, by
+// name.
+func (m *ScrapeManager) GetJobs() []JobStatus {
+	state := m.breaker.State()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	names := slices.Sorted(maps.Keys(m.jobs))
+	res := make([]JobStatus, 0, len(names))
+	for _, name := range names {
+		j := m.jobs[name]
+		status := JobStatus{
+			Name:    name,
+			Working: j.working,
+			Started: j.time,
+		}
+		// The IMDb import is the only job not talking to TMDB.
+		if j.working && state.Open && name != "imdb" {
+			status.Paused = true
+			status.PauseReason = state.Reason
+		}
+		res = append(res, status)
 	}
-	go m.startMovieSyncInternal(start, end, overwrite)
-	return nil
+	return res
 }
 
-func (m *ScrapeManager) startMovieSyncInternal(start int, end int, overwrite bool) {
-	m.movieWorking = true
-	tm := time.Now()
-	m.movieTime = &tm
-	ctx, cFunc := context.WithCancel(context.Background())
-	m.movieCancel = cFunc
-	err := m.movieC.Start(ctx, start, end, overwrite)
-	if err != nil {
-		fmt.Println("Movie scraper errored out with", err)
+// getJob must be called with mtx held.
+func (m *ScrapeManager) getJob(name string) *job {
+	j, ok := m.jobs[name]
+	if !ok {
+		j = &job{}
+		m.jobs[name] = j
 	}
-	if m.movieCancel != nil {
-		m.movieCancel()
-	}
-	m.movieWorking = false
+	return j
 }
 
-func (m *ScrapeManager) StartShowSync(start int, end int, overwrite bool) error {
-	if m.showWorking {
-		return fmt.Errorf("Show sync in currently in progress")
+// The errors startJob refuses a job with.
+var (
+	ErrJobRunning   = errors.New("currently in progress")
+	ErrShuttingDown = errors.New("shutting down")
+)
+
+// startJob runs fn in the background under the given job name unless a job
+// with that name is already running.
+func (m *ScrapeManager) startJob(name string, fn func(ctx context.Context) error) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.closed {
+		return fmt.Errorf("not starting %s sync: %w", name, ErrShuttingDown)
+	}
+	j := m.getJob(name)
+	if j.working {
+		return fmt.Errorf("%s sync is %w", name, ErrJobRunning)
 	}
+	ctx, cFunc := context.WithCancel(context.Background())
+	tm := time.Now()
+	j.working = true
+	j.time = &tm
+	j.cancel = cFunc
 
-	go m.startShowSyncInternal(start, end, overwrite)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		err := fn(ctx)
+		if err != nil {
+			fmt.Println(name, "scraper errored out with", err)
+		}
+		cFunc()
+
+		m.mtx.Lock()
+		j.working = false
+		j.cancel = nil
+		m.mtx.Unlock()
+	}()
 	return nil
 }
 
-func (m *ScrapeManager) startShowSyncInternal(start int, end int, overwrite bool) {
-	m.showWorking = true
-	tm := time.Now()
-	m.showTime = &tm
-	ctx, cFunc := context.WithCancel(context.Background())
-	m.showCancel = cFunc
-	err := m.showC.Start(ctx, start, end, overwrite)
-	if err != nil {
-		fmt.Println("Show scraper errored out with", err)
-	}
-	if m.showCancel != nil {
-		m.showCancel()
+func (m *ScrapeManager) stopJob(name string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	j := m.getJob(name)
+	if j.cancel != nil {
+		j.cancel()
+		j.cancel = nil
 	}
-	m.showWorking = false
+}
+
+func (m *ScrapeManager) StartMovieSync(start int, end int, overwrite bool, opts FetchOptions) error {
+	return m.startJob("movie", func(ctx context.Context) error {
+		return m.movieC.Start(ctx, start, end, overwrite, opts)
+	})
+}
+
+func (m *ScrapeManager) StartShowSync(start int, end int, overwrite bool, opts FetchOptions) error {
+	return m.startJob("show", func(ctx context.Context) error {
+		return m.showC.Start(ctx, start, end, overwrite, opts)
+	})
+}
+
+func (m *ScrapeManager) StartPersonSync(start int, end int, overwrite bool, referenced bool, opts FetchOptions) error {
+	return m.startJob("person", func(ctx context.Context) error {
+		return m.personC.Start(ctx, start, end, overwrite, referenced, opts)
+	})
+}
+
+// StartCompanySync crawls the companies or networks, tp is company or
+// network.
+func (m *ScrapeManager) StartCompanySync(tp string, overwrite bool) error {
+	return m.startJob(tp, func(ctx context.Context) error {
+		return m.orgC.Start(ctx, tp, overwrite)
+	})
 }
 
 func (m *ScrapeManager) StartIMDBSync() error {
-	if m.imdbWorking {
-		return fmt.Errorf("IMDB sync is currently in progress")
-	}
-	go m.startIMDBSyncInternal()
-	return nil
+	return m.startJob("imdb", m.imdbI.Start)
 }
 
-func (m *ScrapeManager) startIMDBSyncInternal() {
-	m.imdbWorking = true
-	tm := time.Now()
-	m.imdbTime = &tm
-	ctx, cFunc := context.WithCancel(context.Background())
-	m.imdbCancel = cFunc
-	err := m.imdbI.Start(ctx)
-	if err != nil {
-		fmt.Println("Imdb scraper errored out with", err)
-	}
-	if m.imdbCancel != nil {
-		m.imdbCancel()
-	}
-	m.imdbWorking = false
+func (m *ScrapeManager) StartReferenceSync() error {
+	return m.startJob("reference", m.refS.Start)
 }
 
 func (m *ScrapeManager) StopMovieScrape() {
-	if m.movieCancel != nil {
-		m.movieCancel()
-		m.movieCancel = nil
-	}
+	m.stopJob("movie")
 }
 
 func (m *ScrapeManager) StopShowScrape() {
-	if m.showCancel != nil {
-		m.showCancel()
-		m.showCancel = nil
-	}
+	m.stopJob("show")
+}
+
+func (m *ScrapeManager) StopPersonScrape() {
+	m.stopJob("person")
+}
+
+func (m *ScrapeManager) StopCompanyScrape(tp string) {
+	m.stopJob(tp)
 }
 
 func (m *ScrapeManager) StopImdbScrape() {
-	if m.imdbCancel != nil {
-		m.imdbCancel()
-		m.imdbCancel = nil
+	m.stopJob("imdb")
+}
+
+func (m *ScrapeManager) StartChartSync() error {
+	return m.startJob("charts", m.chartS.Start)
+}
+
+func (m *ScrapeManager) StartDiscoverSync(spec DiscoverSpec, overwrite bool, opts FetchOptions) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	return m.startJob("discover", func(ctx context.Context) error {
+		return m.discS.Start(ctx, spec, overwrite, opts)
+	})
+}
+
+// StartStaleRefresh refreshes the stalest items of types on every refresh
+// interval, budget overrides the configured budget when positive.
+func (m *ScrapeManager) StartStaleRefresh(types []string, budget int) error {
+	return m.startJob("stale", func(ctx context.Context) error {
+		return m.staleR.Start(ctx, types, budget)
+	})
+}
+
+func (m *ScrapeManager) StopStaleRefresh() {
+	m.stopJob("stale")
+}
+
+func (m *ScrapeManager) StopDiscoverSync() {
+	m.stopJob("discover")
+}
+
+func (m *ScrapeManager) StopChartSync() {
+	m.stopJob("charts")
+}
+
+func (m *ScrapeManager) StopReferenceSync() {
+	m.stopJob("reference")
+}
+
+// ShutDown cancels every running job and waits for them to finish their
+// current item (or roll back) until ctx expires.
+func (m *ScrapeManager) ShutDown(ctx context.Context) error {
+	m.mtx.Lock()
+	m.closed = true
+	for _, j := range m.jobs {
+		if j.cancel != nil {
+			j.cancel()
+		}
+	}
+	m.mtx.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-func (m *ScrapeManager) ShutDown() {
-	if m.movieCancel != nil {
-		m.movieCancel()
+// RetryFailed fetches every item of the given type that has a failure
+// recorded again, only those whose latest failure is of kind when it is
+// set. The failures recorded before the retry are cleared when the item
+// now succeeds or turns out not to exist, or when the retry recorded a
+// fresh one. It returns how many items were recovered, that is left
+// without any failure.
+func (m *ScrapeManager) RetryFailed(ctx context.Context, tp string, kind ErrorKind) (int, error) {
+	var fetch func(ctx context.Context, v int, opts FetchOptions) error
+	opts := FetchOptions{}
+	switch tp {
+	case "movie":
+		fetch = m.movieC.Fetch
+	case "show":
+		fetch = m.showC.Fetch
+	case "episode":
+		// Episode failures are recorded under the show id, the show is
+		// fetched again with all of its episodes.
+		fetch = m.showC.Fetch
+		opts.Deep = true
+	case "person":
+		fetch = m.personC.Fetch
+	case "company", "network":
+		fetch = func(ctx context.Context, v int, opts FetchOptions) error {
+			return m.orgC.Fetch(ctx, tp, v)
+		}
+	case "collection":
+		fetch = func(ctx context.Context, v int, opts FetchOptions) error {
+			return m.movieC.FetchCollection(ctx, int64(v))
+		}
+	default:
+		return 0, fmt.Errorf("Invalid type %s, retry movie, show, episode, person, company, network or collection", tp)
+	}
+
+	ids, err := m.repo.GetFailedIDs(ctx, tp, kind)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Printf("Retrying %d failed %s items\n", len(ids), tp)
+
+	storeCtx := storeContext(ctx)
+	recovered := 0
+	for _, v := range ids {
+		if ctx.Err() != nil {
+			return recovered, nil
+		}
+		upTo, err := m.repo.LatestFailedID(ctx, tp, v)
+		if err != nil {
+			return recovered, err
+		}
+		err = fetch(ctx, v, opts)
+		if ctx.Err() != nil {
+			return recovered, nil
+		}
+		if err != nil && !isNotFound(err) {
+			latest, err := m.repo.LatestFailedID(storeCtx, tp, v)
+			if err != nil || latest == upTo {
+				// Failed without a fresh failure of this type, keep the old
+				// ones.
+				continue
+			}
+		}
+		left, err := m.repo.ClearFailed(storeCtx, tp, v, upTo)
+		if err != nil {
+			fmt.Println("Error clearing failed entries for", v, err)
+			continue
+		}
+		if left == 0 {
+			recovered++
+		}
 	}
-	if m.showCancel != nil {
-		m.showCancel()
+	return recovered, nil
+}
+
+// Refresh fetches a single item synchronously and returns the stored
+// record. Unlike a ranged sync it leaves the crawl progress alone.
+func (m *ScrapeManager) Refresh(ctx context.Context, tp string, id int, opts FetchOptions) ([]byte, error) {
+	var err error
+	switch tp {
+	case "movie":
+		err = m.movieC.Fetch(ctx, id, opts)
+	case "show":
+		err = m.showC.Fetch(ctx, id, opts)
+	case "person":
+		err = m.personC.Fetch(ctx, id, opts)
+	default:
+		return nil, fmt.Errorf("Invalid type %s", tp)
 	}
-	if m.imdbCancel != nil {
-		m.imdbCancel()
+	if err != nil {
+		return nil, err
 	}
+	return m.repo.GetDetails(ctx, tp, id)
 }
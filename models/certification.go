@@ -0,0 +1,45 @@
+package models
+
+// ReleaseDates is the appended /release_dates response of a movie.
+type ReleaseDates struct {
+	Results []ReleaseDateCountry `json:"results"`
+}
+
+type ReleaseDateCountry struct {
+	ISO3166_1    string        `json:"iso_3166_1"`
+	ReleaseDates []ReleaseDate `json:"release_dates"`
+}
+
+// ReleaseDate Type is 1 premiere, 2 limited theatrical, 3 theatrical,
+// 4 digital, 5 physical, 6 tv.
+type ReleaseDate struct {
+	Certification string   `json:"certification"`
+	Descriptors   []string `json:"descriptors"`
+	ISO639_1      string   `json:"iso_639_1"`
+	Note          string   `json:"note"`
+	ReleaseDate   string   `json:"release_date"`
+	Type          int64    `json:"type"`
+}
+
+// ContentRatings is the appended /content_ratings response of a show.
+type ContentRatings struct {
+	Results []ContentRating `json:"results"`
+}
+
+type ContentRating struct {
+	Descriptors []string `json:"descriptors"`
+	ISO3166_1   string   `json:"iso_3166_1"`
+	Rating      string   `json:"rating"`
+}
+
+// CertificationList is the /certification/{movie,tv}/list response, keyed
+// by country.
+type CertificationList struct {
+	Certifications map[string][]Certification `json:"certifications"`
+}
+
+type Certification struct {
+	Certification string `json:"certification"`
+	Meaning       string `json:"meaning"`
+	Order         int64  `json:"order"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// ChartPage is a page of the trending and the popular, now playing,
+// upcoming, on the air and airing today lists, and of the discover
+// results.
+type ChartPage struct {
+	Page         int64        `json:"page"`
+	Results      []ChartEntry `json:"results"`
+	TotalPages   int64        `json:"total_pages"`
+	TotalResults int64        `json:"total_results"`
+}
+
+// ChartEntry keeps what a snapshot needs from a list result. MediaType is
+// only set by the trending lists.
+type ChartEntry struct {
+	ID         int64   `json:"id"`
+	MediaType  string  `json:"media_type"`
+	Title      string  `json:"title"`
+	Name       string  `json:"name"`
+	Popularity float64 `json:"popularity"`
+}
@@ -0,0 +1,59 @@
+package models
+
+// TMDBCompany is a /company/{id} response together with its alternative
+// names and logos.
+type TMDBCompany struct {
+	Description      string           `json:"description"`
+	Headquarters     string           `json:"headquarters"`
+	Homepage         string           `json:"homepage"`
+	ID               int64            `json:"id"`
+	LogoPath         *string          `json:"logo_path"`
+	Name             string           `json:"name"`
+	OriginCountry    string           `json:"origin_country"`
+	ParentCompany    *ParentCompany   `json:"parent_company"`
+	AlternativeNames AlternativeNames `json:"alternative_names"`
+	Images           LogoImages       `json:"images"`
+}
+
+// TMDBNetwork is a /network/{id} response together with its alternative
+// names and logos.
+type TMDBNetwork struct {
+	Headquarters     string           `json:"headquarters"`
+	Homepage         string           `json:"homepage"`
+	ID               int64            `json:"id"`
+	LogoPath         *string          `json:"logo_path"`
+	Name             string           `json:"name"`
+	OriginCountry    string           `json:"origin_country"`
+	AlternativeNames AlternativeNames `json:"alternative_names"`
+	Images           LogoImages       `json:"images"`
+}
+
+type ParentCompany struct {
+	ID       int64   `json:"id"`
+	LogoPath *string `json:"logo_path"`
+	Name     string  `json:"name"`
+}
+
+type AlternativeNames struct {
+	Results []AlternativeName `json:"results"`
+}
+
+type AlternativeName struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type LogoImages struct {
+	Logos []Logo `json:"logos"`
+}
+
+type Logo struct {
+	AspectRatio float64 `json:"aspect_ratio"`
+	FilePath    string  `json:"file_path"`
+	FileType    string  `json:"file_type"`
+	Height      int64   `json:"height"`
+	ID          string  `json:"id"`
+	VoteAverage float64 `json:"vote_average"`
+	VoteCount   int64   `json:"vote_count"`
+	Width       int64   `json:"width"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DateLayout is how TMDB writes dates.
+const DateLayout = "2006-01-02"
+
+// Date is a TMDB calendar date. TMDB sends an unknown date as null or as
+// "", both decode to a Date without time and are written back the way they
+// came. A date that doesn't parse doesn't fail the whole item either, it is
+// unknown but kept as it came and written back unchanged, see Malformed.
+type Date struct {
+	time.Time
+	// blank is a date TMDB sent as "".
+	blank bool
+	// malformed is the JSON of a date that didn't parse.
+	malformed string
+}
+
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{Time: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// ParseDate parses a YYYY-MM-DD date, "" gives a blank Date.
+func ParseDate(s string) (Date, error) {
+	if s == "" {
+		return Date{blank: true}, nil
+	}
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q", s)
+	}
+	return Date{Time: t}, nil
+}
+
+// IsZero reports a date that was null or missing, so omitzero leaves out
+// only those. Use Known to tell whether there is a date at all.
+func (d Date) IsZero() bool {
+	return d.Time.IsZero() && !d.blank && d.malformed == ""
+}
+
+// Malformed returns the JSON of a date that didn't parse, "" for every
+// other Date.
+func (d Date) Malformed() string {
+	return d.malformed
+}
+
+// Known reports whether d holds an actual date.
+func (d Date) Known() bool {
+	return !d.Time.IsZero()
+}
+
+func (d Date) String() string {
+	if !d.Known() {
+		return ""
+	}
+	return d.Format(DateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.Known() {
+		return json.Marshal(d.Format(DateLayout))
+	}
+	if d.blank {
+		return []byte(`""`), nil
+	}
+	if d.malformed != "" {
+		return []byte(d.malformed), nil
+	}
+	return []byte("null"), nil
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	*d = Date{}
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		d.malformed = string(data)
+		return nil
+	}
+	if s == nil {
+		return nil
+	}
+	parsed, err := ParseDate(*s)
+	if err != nil {
+		d.malformed = string(data)
+		return nil
+	}
+	*d = parsed
+	return nil
+}
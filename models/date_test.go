@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDateJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		known bool
+		want  time.Time
+		out   string
+	}{
+		{"date", `"2011-04-17"`, true, time.Date(2011, 4, 17, 0, 0, 0, 0, time.UTC), `"2011-04-17"`},
+		{"null", `null`, false, time.Time{}, `null`},
+		{"empty", `""`, false, time.Time{}, `""`},
+		{"malformed", `"2011-13-45"`, false, time.Time{}, `"2011-13-45"`},
+		{"partial", `"2011"`, false, time.Time{}, `"2011"`},
+		{"not a string", `20110417`, false, time.Time{}, `20110417`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Date
+			if err := json.Unmarshal([]byte(tt.in), &d); err != nil {
+				t.Fatalf("decode %s: %v", tt.in, err)
+			}
+			if d.Known() != tt.known || !d.Time.Equal(tt.want) {
+				t.Errorf("decode %s = %v known %v, want %v known %v", tt.in, d.Time, d.Known(), tt.want, tt.known)
+			}
+			out, err := json.Marshal(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.out {
+				t.Errorf("encode = %s, want %s", out, tt.out)
+			}
+		})
+	}
+}
+
+// A bad date must not fail the item it is part of, and is stored as it
+// came.
+func TestDateMalformedInItem(t *testing.T) {
+	var e Episode
+	err := json.Unmarshal([]byte(`{"id": 1, "air_date": "soon", "name": "Pilot"}`), &e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Name != "Pilot" || e.AirDate.Known() || e.AirDate.Malformed() != `"soon"` {
+		t.Errorf("got %+v", e)
+	}
+
+	out, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(out, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["air_date"] != "soon" {
+		t.Errorf("air_date = %v, want soon", m["air_date"])
+	}
+}
+
+func TestDateOmitZero(t *testing.T) {
+	credit := PersonCredit{MediaType: "movie", ReleaseDate: Date{blank: true}}
+	out, err := json.Marshal(credit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(out, &m); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m["release_date"]; !ok || v != "" {
+		t.Errorf(`release_date = %v, want ""`, v)
+	}
+	if _, ok := m["first_air_date"]; ok {
+		t.Error("first_air_date should be left out")
+	}
+}
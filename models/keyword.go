@@ -0,0 +1,46 @@
+package models
+
+// Keywords is the appended /keywords response. Movies return the list
+// under "keywords", shows under "results".
+type Keywords struct {
+	Keywords []Keyword `json:"keywords,omitempty"`
+	Results  []Keyword `json:"results,omitempty"`
+}
+
+func (k Keywords) All() []Keyword {
+	if len(k.Keywords) > 0 {
+		return k.Keywords
+	}
+	return k.Results
+}
+
+type Keyword struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// Reviews is a page of the /reviews response. Once every page has been
+// fetched Results holds all of them.
+type Reviews struct {
+	Page         int64    `json:"page"`
+	Results      []Review `json:"results"`
+	TotalPages   int64    `json:"total_pages"`
+	TotalResults int64    `json:"total_results"`
+}
+
+type Review struct {
+	Author        string        `json:"author"`
+	AuthorDetails AuthorDetails `json:"author_details"`
+	Content       string        `json:"content"`
+	CreatedAt     string        `json:"created_at"`
+	ID            string        `json:"id"`
+	UpdatedAt     string        `json:"updated_at"`
+	URL           string        `json:"url"`
+}
+
+type AuthorDetails struct {
+	Name       string   `json:"name"`
+	Username   string   `json:"username"`
+	AvatarPath *string  `json:"avatar_path"`
+	Rating     *float64 `json:"rating"`
+}
@@ -0,0 +1,61 @@
+package models
+
+type TMDBPerson struct {
+	Adult              bool            `json:"adult"`
+	AlsoKnownAs        []string        `json:"also_known_as"`
+	Biography          string          `json:"biography"`
+	Birthday           Date            `json:"birthday"`
+	Deathday           Date            `json:"deathday"`
+	Gender             int64           `json:"gender"`
+	Homepage           *string         `json:"homepage"`
+	ID                 int64           `json:"id"`
+	ImdbID             *string         `json:"imdb_id"`
+	KnownForDepartment string          `json:"known_for_department"`
+	Name               string          `json:"name"`
+	PlaceOfBirth       *string         `json:"place_of_birth"`
+	Popularity         float64         `json:"popularity"`
+	ProfilePath        *string         `json:"profile_path"`
+	CombinedCredits    CombinedCredits `json:"combined_credits"`
+	ExternalIDS        ExternalIDS     `json:"external_ids"`
+	Images             PersonImages    `json:"images"`
+}
+
+type CombinedCredits struct {
+	Cast []PersonCredit `json:"cast"`
+	Crew []PersonCredit `json:"crew"`
+}
+
+// PersonCredit is an entry of /person/{id}/combined_credits. Movie entries
+// carry Title/ReleaseDate, tv entries Name/FirstAirDate, MediaType tells
+// them apart.
+type PersonCredit struct {
+	Adult            bool     `json:"adult"`
+	BackdropPath     *string  `json:"backdrop_path"`
+	GenreIDS         []int64  `json:"genre_ids"`
+	ID               int64    `json:"id"`
+	OriginCountry    []string `json:"origin_country,omitempty"`
+	OriginalLanguage string   `json:"original_language"`
+	OriginalTitle    string   `json:"original_title,omitempty"`
+	OriginalName     string   `json:"original_name,omitempty"`
+	Overview         string   `json:"overview"`
+	Popularity       float64  `json:"popularity"`
+	PosterPath       *string  `json:"poster_path"`
+	ReleaseDate      Date     `json:"release_date,omitzero"`
+	FirstAirDate     Date     `json:"first_air_date,omitzero"`
+	Title            string   `json:"title,omitempty"`
+	Name             string   `json:"name,omitempty"`
+	Video            *bool    `json:"video,omitempty"`
+	VoteAverage      float64  `json:"vote_average"`
+	VoteCount        int64    `json:"vote_count"`
+	Character        *string  `json:"character,omitempty"`
+	CreditID         string   `json:"credit_id"`
+	Order            *int64   `json:"order,omitempty"`
+	EpisodeCount     *int64   `json:"episode_count,omitempty"`
+	Department       *string  `json:"department,omitempty"`
+	Job              *string  `json:"job,omitempty"`
+	MediaType        string   `json:"media_type"`
+}
+
+type PersonImages struct {
+	Profiles []Backdrop `json:"profiles"`
+}
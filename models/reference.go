@@ -0,0 +1,32 @@
+package models
+
+// Country is an entry of the /configuration/countries response.
+type Country struct {
+	ISO3166_1   string `json:"iso_3166_1"`
+	EnglishName string `json:"english_name"`
+	NativeName  string `json:"native_name"`
+}
+
+// Language is an entry of the /configuration/languages response.
+type Language struct {
+	ISO639_1    string `json:"iso_639_1"`
+	EnglishName string `json:"english_name"`
+	Name        string `json:"name"`
+}
+
+// Configuration is the /configuration response, it tells how to build
+// image urls from the paths in the other responses.
+type Configuration struct {
+	Images     ConfigurationImages `json:"images"`
+	ChangeKeys []string            `json:"change_keys"`
+}
+
+type ConfigurationImages struct {
+	BaseURL       string   `json:"base_url"`
+	SecureBaseURL string   `json:"secure_base_url"`
+	BackdropSizes []string `json:"backdrop_sizes"`
+	LogoSizes     []string `json:"logo_sizes"`
+	PosterSizes   []string `json:"poster_sizes"`
+	ProfileSizes  []string `json:"profile_sizes"`
+	StillSizes    []string `json:"still_sizes"`
+}
@@ -10,12 +10,12 @@ type PaginatedResponse struct {
 }
 
 type CreatedBy struct {
-	ID           int64  `json:"id"`
-	CreditID     string `json:"credit_id"`
-	Name         string `json:"name"`
-	OriginalName string `json:"original_name"`
-	Gender       int64  `json:"gender"`
-	ProfilePath  string `json:"profile_path"`
+	ID           int64   `json:"id"`
+	CreditID     string  `json:"credit_id"`
+	Name         string  `json:"name"`
+	OriginalName string  `json:"original_name"`
+	Gender       int64   `json:"gender"`
+	ProfilePath  *string `json:"profile_path"`
 }
 
 type Credits struct {
@@ -40,15 +40,17 @@ type Cast struct {
 }
 
 type ExternalIDS struct {
-	ImdbID      string `json:"imdb_id"`
-	FreebaseMid string `json:"freebase_mid"`
-	FreebaseID  string `json:"freebase_id"`
-	TvdbID      int64  `json:"tvdb_id"`
-	TvrageID    int64  `json:"tvrage_id"`
-	WikidataID  string `json:"wikidata_id"`
-	FacebookID  string `json:"facebook_id"`
-	InstagramID string `json:"instagram_id"`
-	TwitterID   string `json:"twitter_id"`
+	ImdbID      *string `json:"imdb_id"`
+	FreebaseMid *string `json:"freebase_mid"`
+	FreebaseID  *string `json:"freebase_id"`
+	TvdbID      *int64  `json:"tvdb_id"`
+	TvrageID    *int64  `json:"tvrage_id"`
+	WikidataID  *string `json:"wikidata_id"`
+	FacebookID  *string `json:"facebook_id"`
+	InstagramID *string `json:"instagram_id"`
+	TwitterID   *string `json:"twitter_id"`
+	TiktokID    *string `json:"tiktok_id"`
+	YoutubeID   *string `json:"youtube_id"`
 }
 
 type Episode struct {
@@ -57,21 +59,38 @@ type Episode struct {
 	Overview       string  `json:"overview"`
 	VoteAverage    float64 `json:"vote_average"`
 	VoteCount      float64 `json:"vote_count"`
-	AirDate        string  `json:"air_date"`
+	AirDate        Date    `json:"air_date"`
 	EpisodeNumber  int64   `json:"episode_number"`
 	EpisodeType    string  `json:"episode_type"`
 	ProductionCode string  `json:"production_code"`
 	Runtime        int64   `json:"runtime"`
 	SeasonNumber   int64   `json:"season_number"`
 	ShowID         int64   `json:"show_id"`
-	StillPath      string  `json:"still_path"`
+	StillPath      *string `json:"still_path"`
+	// Crew and GuestStars come with season and episode responses, the
+	// remaining fields only with a deep episode fetch.
+	Crew        []Cast          `json:"crew,omitempty"`
+	GuestStars  []GuestStar     `json:"guest_stars,omitempty"`
+	Credits     *EpisodeCredits `json:"credits,omitempty"`
+	ExternalIDS *ExternalIDS    `json:"external_ids,omitempty"`
+	Images      *EpisodeImages  `json:"images,omitempty"`
+}
+
+type EpisodeCredits struct {
+	Cast       []Cast      `json:"cast"`
+	Crew       []Cast      `json:"crew"`
+	GuestStars []GuestStar `json:"guest_stars"`
+}
+
+type EpisodeImages struct {
+	Stills []Backdrop `json:"stills"`
 }
 
 type Network struct {
-	ID            int64  `json:"id"`
-	LogoPath      string `json:"logo_path"`
-	Name          string `json:"name"`
-	OriginCountry string `json:"origin_country"`
+	ID            int64   `json:"id"`
+	LogoPath      *string `json:"logo_path"`
+	Name          string  `json:"name"`
+	OriginCountry string  `json:"origin_country"`
 }
 
 type ProductionCountry struct {
@@ -101,19 +120,19 @@ type SpokenLanguage struct {
 
 type TMDBShow struct {
 	Adult               bool                `json:"adult"`
-	BackdropPath        string              `json:"backdrop_path"`
+	BackdropPath        *string             `json:"backdrop_path"`
 	CreatedBy           []CreatedBy         `json:"created_by"`
 	EpisodeRunTime      []int64             `json:"episode_run_time"`
-	FirstAirDate        string              `json:"first_air_date"`
+	FirstAirDate        Date                `json:"first_air_date"`
 	Genres              []Genre             `json:"genres"`
 	Homepage            string              `json:"homepage"`
 	ID                  int64               `json:"id"`
 	InProduction        bool                `json:"in_production"`
 	Languages           []string            `json:"languages"`
-	LastAirDate         string              `json:"last_air_date"`
-	LastEpisodeToAir    Episode             `json:"last_episode_to_air"`
+	LastAirDate         Date                `json:"last_air_date"`
+	LastEpisodeToAir    *Episode            `json:"last_episode_to_air"`
 	Name                string              `json:"name"`
-	NextEpisodeToAir    Episode             `json:"next_episode_to_air"`
+	NextEpisodeToAir    *Episode            `json:"next_episode_to_air"`
 	Networks            []Network           `json:"networks"`
 	NumberOfEpisodes    int64               `json:"number_of_episodes"`
 	NumberOfSeasons     int64               `json:"number_of_seasons"`
@@ -122,7 +141,7 @@ type TMDBShow struct {
 	OriginalName        string              `json:"original_name"`
 	Overview            string              `json:"overview"`
 	Popularity          float64             `json:"popularity"`
-	PosterPath          string              `json:"poster_path"`
+	PosterPath          *string             `json:"poster_path"`
 	ProductionCompanies []Network           `json:"production_companies"`
 	ProductionCountries []ProductionCountry `json:"production_countries"`
 	SpokenLanguages     []SpokenLanguage    `json:"spoken_languages"`
@@ -138,6 +157,12 @@ type TMDBShow struct {
 	Similar             SimilarShow         `json:"similar"`
 	Recommendations     SimilarShow         `json:"recommendations"`
 	Videos              Videos              `json:"videos"`
+	Translations        Translations        `json:"translations"`
+	AlternativeTitles   AlternativeTitles   `json:"alternative_titles"`
+	ContentRatings      ContentRatings      `json:"content_ratings"`
+	WatchProviders      WatchProviders      `json:"watch/providers"`
+	Keywords            Keywords            `json:"keywords"`
+	Reviews             Reviews             `json:"reviews"`
 }
 
 type GuestStar struct {
@@ -156,57 +181,63 @@ type GuestStar struct {
 
 type Season struct {
 	ID           string    `json:"_id"`
-	AirDate      string    `json:"air_date"`
+	AirDate      Date      `json:"air_date"`
 	Episodes     []Episode `json:"episodes"`
 	Name         string    `json:"name"`
 	Networks     []Network `json:"networks"`
 	Overview     string    `json:"overview"`
-	PosterPath   string    `json:"poster_path"`
+	PosterPath   *string   `json:"poster_path"`
 	SeasonNumber int64     `json:"season_number"`
 	VoteAverage  float64   `json:"vote_average"`
 }
 
 type TMDBMovie struct {
-	ExternalIDS         ExternalIDS         `json:"external_ids"`
-	Adult               bool                `json:"adult"`
-	Credits             Credits             `json:"credits"`
-	BackdropPath        string              `json:"backdrop_path"`
-	BelongsToCollection BelongsToCollection `json:"belongs_to_collection"`
-	Collection          Collection          `json:"collection"`
-	Budget              int64               `json:"budget"`
-	Genres              []Genre             `json:"genres"`
-	Homepage            string              `json:"homepage"`
-	ID                  int64               `json:"id"`
-	ImdbID              string              `json:"imdb_id"`
-	OriginCountry       []string            `json:"origin_country"`
-	OriginalLanguage    string              `json:"original_language"`
-	OriginalTitle       string              `json:"original_title"`
-	Overview            string              `json:"overview"`
-	Popularity          float64             `json:"popularity"`
-	PosterPath          string              `json:"poster_path"`
-	ProductionCompanies []ProductionCompany `json:"production_companies"`
-	ProductionCountries []ProductionCountry `json:"production_countries"`
-	ReleaseDate         string              `json:"release_date"`
-	Revenue             int64               `json:"revenue"`
-	Runtime             int64               `json:"runtime"`
-	SpokenLanguages     []SpokenLanguage    `json:"spoken_languages"`
-	Status              string              `json:"status"`
-	Tagline             string              `json:"tagline"`
-	Title               string              `json:"title"`
-	Video               bool                `json:"video"`
-	VoteAverage         float64             `json:"vote_average"`
-	VoteCount           int64               `json:"vote_count"`
-	Images              Images              `json:"images"`
-	Similar             SimilarMovie        `json:"similar"`
-	Recommendations     SimilarMovie        `json:"recommendations"`
-	Videos              Videos              `json:"videos"`
+	ExternalIDS         ExternalIDS          `json:"external_ids"`
+	Adult               bool                 `json:"adult"`
+	Credits             Credits              `json:"credits"`
+	BackdropPath        *string              `json:"backdrop_path"`
+	BelongsToCollection *BelongsToCollection `json:"belongs_to_collection"`
+	CollectionID        int64                `json:"collection_id,omitempty"`
+	Budget              int64                `json:"budget"`
+	Genres              []Genre              `json:"genres"`
+	Homepage            string               `json:"homepage"`
+	ID                  int64                `json:"id"`
+	ImdbID              *string              `json:"imdb_id"`
+	OriginCountry       []string             `json:"origin_country"`
+	OriginalLanguage    string               `json:"original_language"`
+	OriginalTitle       string               `json:"original_title"`
+	Overview            string               `json:"overview"`
+	Popularity          float64              `json:"popularity"`
+	PosterPath          *string              `json:"poster_path"`
+	ProductionCompanies []ProductionCompany  `json:"production_companies"`
+	ProductionCountries []ProductionCountry  `json:"production_countries"`
+	ReleaseDate         Date                 `json:"release_date"`
+	Revenue             int64                `json:"revenue"`
+	Runtime             int64                `json:"runtime"`
+	SpokenLanguages     []SpokenLanguage     `json:"spoken_languages"`
+	Status              string               `json:"status"`
+	Tagline             string               `json:"tagline"`
+	Title               string               `json:"title"`
+	Video               bool                 `json:"video"`
+	VoteAverage         float64              `json:"vote_average"`
+	VoteCount           int64                `json:"vote_count"`
+	Images              Images               `json:"images"`
+	Similar             SimilarMovie         `json:"similar"`
+	Recommendations     SimilarMovie         `json:"recommendations"`
+	Videos              Videos               `json:"videos"`
+	Translations        Translations         `json:"translations"`
+	AlternativeTitles   AlternativeTitles    `json:"alternative_titles"`
+	ReleaseDates        ReleaseDates         `json:"release_dates"`
+	WatchProviders      WatchProviders       `json:"watch/providers"`
+	Keywords            Keywords             `json:"keywords"`
+	Reviews             Reviews              `json:"reviews"`
 }
 
 type BelongsToCollection struct {
-	ID           int64  `json:"id"`
-	Name         string `json:"name"`
-	PosterPath   string `json:"poster_path"`
-	BackdropPath string `json:"backdrop_path"`
+	ID           int64   `json:"id"`
+	Name         string  `json:"name"`
+	PosterPath   *string `json:"poster_path"`
+	BackdropPath *string `json:"backdrop_path"`
 }
 
 type Images struct {
@@ -218,8 +249,8 @@ type Images struct {
 type Backdrop struct {
 	AspectRatio float64 `json:"aspect_ratio"`
 	Height      int64   `json:"height"`
-	ISO3166_1   string  `json:"iso_3166_1"`
-	ISO639_1    string  `json:"iso_639_1"`
+	ISO3166_1   *string `json:"iso_3166_1"`
+	ISO639_1    *string `json:"iso_639_1"`
 	FilePath    string  `json:"file_path"`
 	VoteAverage float64 `json:"vote_average"`
 	VoteCount   int64   `json:"vote_count"`
@@ -227,10 +258,10 @@ type Backdrop struct {
 }
 
 type ProductionCompany struct {
-	ID            int64  `json:"id"`
-	LogoPath      string `json:"logo_path"`
-	Name          string `json:"name"`
-	OriginCountry string `json:"origin_country"`
+	ID            int64   `json:"id"`
+	LogoPath      *string `json:"logo_path"`
+	Name          string  `json:"name"`
+	OriginCountry string  `json:"origin_country"`
 }
 
 type SimilarMovie struct {
@@ -249,15 +280,15 @@ type SimilarShow struct {
 
 type SimilarResultMovie struct {
 	Adult            bool    `json:"adult"`
-	BackdropPath     string  `json:"backdrop_path"`
+	BackdropPath     *string `json:"backdrop_path"`
 	GenreIDS         []int64 `json:"genre_ids"`
 	ID               int64   `json:"id"`
 	OriginalLanguage string  `json:"original_language"`
 	OriginalTitle    string  `json:"original_title"`
 	Overview         string  `json:"overview"`
 	Popularity       float64 `json:"popularity"`
-	PosterPath       string  `json:"poster_path"`
-	ReleaseDate      string  `json:"release_date"`
+	PosterPath       *string `json:"poster_path"`
+	ReleaseDate      Date    `json:"release_date"`
 	Title            string  `json:"title"`
 	Video            bool    `json:"video"`
 	VoteAverage      float64 `json:"vote_average"`
@@ -266,7 +297,7 @@ type SimilarResultMovie struct {
 
 type SimilarResultShow struct {
 	Adult            bool     `json:"adult"`
-	BackdropPath     string   `json:"backdrop_path"`
+	BackdropPath     *string  `json:"backdrop_path"`
 	GenreIDS         []int64  `json:"genre_ids"`
 	ID               int64    `json:"id"`
 	OriginCountry    []string `json:"origin_country"`
@@ -274,37 +305,39 @@ type SimilarResultShow struct {
 	OriginalName     string   `json:"original_name"`
 	Overview         string   `json:"overview"`
 	Popularity       float64  `json:"popularity"`
-	PosterPath       string   `json:"poster_path"`
-	FirstAirDate     string   `json:"first_air_date"`
+	PosterPath       *string  `json:"poster_path"`
+	FirstAirDate     Date     `json:"first_air_date"`
 	Name             string   `json:"name"`
 	VoteAverage      float64  `json:"vote_average"`
 	VoteCount        int64    `json:"vote_count"`
 }
 
 type Collection struct {
-	ID               int64  `json:"id"`
-	Name             string `json:"name"`
-	OriginalLanguage string `json:"original_language"`
-	OriginalName     string `json:"original_name"`
-	Overview         string `json:"overview"`
-	PosterPath       string `json:"poster_path"`
-	BackdropPath     string `json:"backdrop_path"`
-	Parts            []Part `json:"parts"`
+	ID               int64   `json:"id"`
+	Name             string  `json:"name"`
+	OriginalLanguage string  `json:"original_language"`
+	OriginalName     string  `json:"original_name"`
+	Overview         string  `json:"overview"`
+	PosterPath       *string `json:"poster_path"`
+	BackdropPath     *string `json:"backdrop_path"`
+	Parts            []Part  `json:"parts"`
 }
 
 type Part struct {
 	Adult            bool    `json:"adult"`
-	BackdropPath     string  `json:"backdrop_path"`
+	BackdropPath     *string `json:"backdrop_path"`
 	ID               int64   `json:"id"`
+	Title            string  `json:"title,omitempty"`
+	OriginalTitle    string  `json:"original_title,omitempty"`
 	Name             string  `json:"name"`
 	OriginalName     string  `json:"original_name"`
 	Overview         string  `json:"overview"`
-	PosterPath       string  `json:"poster_path"`
+	PosterPath       *string `json:"poster_path"`
 	MediaType        string  `json:"media_type"`
 	OriginalLanguage string  `json:"original_language"`
 	GenreIDS         []int64 `json:"genre_ids"`
 	Popularity       float64 `json:"popularity"`
-	ReleaseDate      string  `json:"release_date"`
+	ReleaseDate      Date    `json:"release_date"`
 	Video            bool    `json:"video"`
 	VoteAverage      float64 `json:"vote_average"`
 	VoteCount        int64   `json:"vote_count"`
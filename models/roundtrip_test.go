@@ -0,0 +1,107 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestRoundTrip decodes real TMDB payloads into the models and encodes
+// them again. Every value of the payload must come back unchanged, nulls
+// and "" included.
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		fixture string
+		model   func() any
+	}{
+		{"movie.json", func() any { return &TMDBMovie{} }},
+		{"show.json", func() any { return &TMDBShow{} }},
+		{"person.json", func() any { return &TMDBPerson{} }},
+		{"person_dead.json", func() any { return &TMDBPerson{} }},
+		{"collection.json", func() any { return &Collection{} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			model := tt.model()
+			if err := json.Unmarshal(payload, model); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			encoded, err := json.Marshal(model)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+
+			var want, got any
+			if err := decodeNumbers(payload, &want); err != nil {
+				t.Fatal(err)
+			}
+			if err := decodeNumbers(encoded, &got); err != nil {
+				t.Fatal(err)
+			}
+			for _, diff := range contains("", want, got) {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func decodeNumbers(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// contains lists where got lacks or changed a value of want. Keys the
+// models add on top of the payload are fine.
+func contains(path string, want any, got any) []string {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %v, want an object", path, got)}
+		}
+		var diffs []string
+		for k, v := range w {
+			gv, ok := g[k]
+			if !ok {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing", path, k))
+				continue
+			}
+			diffs = append(diffs, contains(path+"."+k, v, gv)...)
+		}
+		return diffs
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			return []string{fmt.Sprintf("%s: got %v, want %v", path, got, want)}
+		}
+		var diffs []string
+		for i := range w {
+			diffs = append(diffs, contains(fmt.Sprintf("%s[%d]", path, i), w[i], g[i])...)
+		}
+		return diffs
+	case json.Number:
+		g, ok := got.(json.Number)
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %v, want %v", path, got, want)}
+		}
+		wf, _ := w.Float64()
+		gf, _ := g.Float64()
+		if wf != gf {
+			return []string{fmt.Sprintf("%s: got %v, want %v", path, got, want)}
+		}
+		return nil
+	}
+	if !reflect.DeepEqual(want, got) {
+		return []string{fmt.Sprintf("%s: got %#v, want %#v", path, got, want)}
+	}
+	return nil
+}
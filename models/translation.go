@@ -0,0 +1,44 @@
+package models
+
+// Translations is the appended /translations response of movies and shows.
+type Translations struct {
+	Translations []Translation `json:"translations"`
+}
+
+type Translation struct {
+	ISO3166_1   string          `json:"iso_3166_1"`
+	ISO639_1    string          `json:"iso_639_1"`
+	Name        string          `json:"name"`
+	EnglishName string          `json:"english_name"`
+	Data        TranslationData `json:"data"`
+}
+
+// TranslationData holds Title for movies and Name for shows.
+type TranslationData struct {
+	Title    string `json:"title,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Overview string `json:"overview"`
+	Homepage string `json:"homepage"`
+	Tagline  string `json:"tagline"`
+	Runtime  int64  `json:"runtime,omitempty"`
+}
+
+// AlternativeTitles is the appended /alternative_titles response. Movies
+// return the list under "titles", shows under "results".
+type AlternativeTitles struct {
+	Titles  []AlternativeTitle `json:"titles,omitempty"`
+	Results []AlternativeTitle `json:"results,omitempty"`
+}
+
+func (a AlternativeTitles) All() []AlternativeTitle {
+	if len(a.Titles) > 0 {
+		return a.Titles
+	}
+	return a.Results
+}
+
+type AlternativeTitle struct {
+	ISO3166_1 string `json:"iso_3166_1"`
+	Title     string `json:"title"`
+	Type      string `json:"type"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// WatchProviders is the appended /watch/providers response of a movie or
+// show, keyed by country.
+type WatchProviders struct {
+	Results map[string]WatchProviderRegion `json:"results"`
+}
+
+// WatchProviderRegion lists the providers of one country by how the title
+// is offered there.
+type WatchProviderRegion struct {
+	Link     string          `json:"link"`
+	Flatrate []WatchProvider `json:"flatrate,omitempty"`
+	Rent     []WatchProvider `json:"rent,omitempty"`
+	Buy      []WatchProvider `json:"buy,omitempty"`
+	Ads      []WatchProvider `json:"ads,omitempty"`
+	Free     []WatchProvider `json:"free,omitempty"`
+}
+
+type WatchProvider struct {
+	DisplayPriority int64  `json:"display_priority"`
+	LogoPath        string `json:"logo_path"`
+	ProviderID      int64  `json:"provider_id"`
+	ProviderName    string `json:"provider_name"`
+}
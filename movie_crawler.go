@@ -4,103 +4,197 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 )
 
 type MovieCrwaler struct {
-	usecase *Usecase
-	at      string
-	repo    *Repo
+	usecase   *Usecase
+	at        string
+	repo      *Repo
+	validator *Validator
+	maxID     int
+	// languages are the translations stored when a job doesn't ask for
+	// specific ones.
+	languages     []string
+	collectionTTL time.Duration
+	// collections remembers when each collection was last stored so the
+	// members of a collection don't each hit the db or TMDB for it.
+	collections map[int64]time.Time
+	mtx         *sync.Mutex
 }
 
-func NewMovieCrawler(usecase *Usecase, at string, repo *Repo) *MovieCrwaler {
+func NewMovieCrawler(usecase *Usecase, at string, repo *Repo, validator *Validator, maxID int, languages []string, collectionTTL time.Duration) *MovieCrwaler {
 	return &MovieCrwaler{
-		usecase: usecase,
-		at:      at,
-		repo:    repo,
+		usecase:       usecase,
+		validator:     validator,
+		at:            at,
+		repo:          repo,
+		maxID:         maxID,
+		languages:     languages,
+		collectionTTL: collectionTTL,
+		collections:   make(map[int64]time.Time),
+		mtx:           &sync.Mutex{},
 	}
 }
 
-func (m *MovieCrwaler) Start(ctx context.Context, start int, end int, overwrite bool) error {
+func (m *MovieCrwaler) Start(ctx context.Context, start int, end int, overwrite bool, opts FetchOptions) error {
 	if end == 0 {
-		end = 2000000
+		end = m.maxID
 	}
 	if start == 0 {
-		index, err := m.GetMovieProgress()
+		index, err := m.GetMovieProgress(ctx)
 		if err != nil {
 			return err
 		}
 		start = index + 1
 	}
 	fmt.Println("Starting crawler for movies from index", start)
-	for i := start; i <= end; i++ {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-			v := i
-
-			if !overwrite {
-				exists, err := m.repo.ItemExists("movie", v)
-				if err != nil {
-					fmt.Println("Error getting item exists", err)
-				}
-				if exists {
-					fmt.Println("Skipping item since its found")
-					err = m.repo.UpdateMovieProgress(v)
-					if err != nil {
-						fmt.Println("Error storing movie progress", err)
-					}
-					continue
-				}
-			}
-
-			exists, err := m.repo.NotFoundExists("movie", v)
-			if err != nil {
-				fmt.Println("Error getting not found", err)
-			}
-			if exists {
-				fmt.Println("Skipping item since it does not exists")
-				err = m.repo.UpdateMovieProgress(v)
-				if err != nil {
-					fmt.Println("Error storing movie progress", err)
-				}
-				continue
-			}
-
-			details, err := m.usecase.GetMovieDetails(fmt.Sprintf("%d", v), m.at)
-			if err != nil {
-				fmt.Println("Error getting movie details for", v)
-				if err.Error() == "not found" {
-					m.repo.InsertNotFound(v, "movie")
-				} else {
-					m.repo.InsertError(v, "movie", err.Error())
-				}
-			} else {
-				bt, err := json.Marshal(details)
-				if err != nil {
-					fmt.Printf("Error marhsalling movie data %d %v\n", v, err)
-					m.repo.InsertError(v, "movie", err.Error())
-				} else {
-					err := m.repo.StoreDetails(v, bt, "movie")
-					if err != nil {
-						fmt.Println("Error storing data in db")
-						m.repo.InsertError(v, "movie", err.Error())
-						continue
-					}
-					err = m.repo.UpdateMovieProgress(v)
-					if err != nil {
-						fmt.Println("Error storing movie progress", err)
-					} else {
-						fmt.Println("Movie details stored for", v)
-					}
-				}
-			}
+	c := crawl{
+		repo:      m.repo,
+		tp:        "movie",
+		overwrite: overwrite,
+		exists: func(ctx context.Context, v int) (bool, error) {
+			return m.repo.ItemExists(ctx, "movie", v)
+		},
+		fetch: func(ctx context.Context, v int) error {
+			return m.Fetch(ctx, v, opts)
+		},
+		checkpoint: m.repo.UpdateMovieProgress,
+	}
+	return c.run(ctx, start-1, rangePages(end))
+}
+
+func (m *MovieCrwaler) GetMovieProgress(ctx context.Context) (int, error) {
+	return m.repo.GetMovieProgress(ctx)
+}
+
+// Fetch downloads a single movie and stores it, recording it in not_found or
+// failed when that does not work. It does not touch the crawl progress.
+func (m *MovieCrwaler) Fetch(ctx context.Context, v int, opts FetchOptions) error {
+	storeCtx := storeContext(ctx)
+
+	details, err := m.usecase.GetMovieDetails(ctx, fmt.Sprintf("%d", v), m.at)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
+		fmt.Println("Error getting movie details for", v)
+		if isNotFound(err) {
+			m.repo.InsertNotFound(storeCtx, v, "movie")
+		} else {
+			m.repo.InsertError(storeCtx, v, "movie", err)
+		}
+		return err
+	}
+
+	err = m.validator.Record(storeCtx, "movie", v, m.validator.Movie(v, details))
+	if err != nil {
+		return err
+	}
+
+	bt, err := json.Marshal(details)
+	if err != nil {
+		fmt.Printf("Error marhsalling movie data %d %v\n", v, err)
+		m.repo.InsertError(storeCtx, v, "movie", err)
+		return err
 	}
 
+	err = m.repo.StoreDetails(storeCtx, v, bt, "movie")
+	if err != nil {
+		fmt.Println("Error storing data in db")
+		m.repo.InsertError(storeCtx, v, "movie", err)
+		return err
+	}
+
+	languages := opts.Languages
+	if len(languages) == 0 {
+		languages = m.languages
+	}
+	err = m.repo.StoreTranslations(storeCtx, "movie", v, languages, translationRecords(details.Translations, details.AlternativeTitles, languages))
+	if err != nil {
+		fmt.Println("Error storing movie translations", v, err)
+	}
+
+	err = m.repo.StoreCertifications(storeCtx, "movie", v, movieCertifications(details.ReleaseDates))
+	if err != nil {
+		fmt.Println("Error storing movie certifications", v, err)
+	}
+
+	err = m.repo.StoreWatchProviders(storeCtx, "movie", v, watchOffers(details.WatchProviders), time.Now())
+	if err != nil {
+		fmt.Println("Error storing movie watch providers", v, err)
+	}
+
+	err = m.repo.StoreKeywords(storeCtx, "movie", v, details.Keywords.All())
+	if err != nil {
+		fmt.Println("Error storing movie keywords", v, err)
+	}
+
+	if details.CollectionID != 0 {
+		m.syncCollection(ctx, details.CollectionID)
+	}
 	return nil
 }
 
-func (m *MovieCrwaler) GetMovieProgress() (int, error) {
-	return m.repo.GetMovieProgress()
+// syncCollection stores the collection with the given id unless it was
+// stored within the collection TTL. Failures are recorded in failed with
+// type collection but don't fail the movie.
+func (m *MovieCrwaler) syncCollection(ctx context.Context, id int64) {
+	m.mtx.Lock()
+	fetchedAt, ok := m.collections[id]
+	m.mtx.Unlock()
+	if ok && time.Since(fetchedAt) < m.collectionTTL {
+		return
+	}
+
+	if !ok {
+		stored, err := m.repo.CollectionFetchedAt(ctx, id)
+		if err != nil {
+			fmt.Println("Error getting collection fetched at", err)
+		}
+		if stored != nil && time.Since(*stored) < m.collectionTTL {
+			m.mtx.Lock()
+			m.collections[id] = *stored
+			m.mtx.Unlock()
+			return
+		}
+	}
+
+	m.FetchCollection(ctx, id)
+}
+
+// FetchCollection downloads a collection and stores it, recording it in
+// failed with type collection when that does not work.
+func (m *MovieCrwaler) FetchCollection(ctx context.Context, id int64) error {
+	storeCtx := storeContext(ctx)
+
+	collection, err := m.usecase.GetCollection(ctx, fmt.Sprintf("%d", id), m.at)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Println("Error getting collection details for", id)
+		m.repo.InsertError(storeCtx, int(id), "collection", err)
+		return err
+	}
+
+	bt, err := json.Marshal(collection)
+	if err != nil {
+		fmt.Printf("Error marshalling collection data %d %v\n", id, err)
+		m.repo.InsertError(storeCtx, int(id), "collection", err)
+		return err
+	}
+
+	err = m.repo.StoreCollection(storeCtx, id, bt)
+	if err != nil {
+		fmt.Println("Error storing collection in db", err)
+		m.repo.InsertError(storeCtx, int(id), "collection", err)
+		return err
+	}
+
+	m.mtx.Lock()
+	m.collections[id] = time.Now()
+	m.mtx.Unlock()
+	return nil
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+type PersonCrawler struct {
+	usecase   *Usecase
+	at        string
+	repo      *Repo
+	validator *Validator
+	maxID     int
+}
+
+func NewPersonCrawler(usecase *Usecase, at string, repo *Repo, validator *Validator, maxID int) *PersonCrawler {
+	return &PersonCrawler{
+		usecase:   usecase,
+		validator: validator,
+		at:        at,
+		repo:      repo,
+		maxID:     maxID,
+	}
+}
+
+// Start crawls person ids from start to end. With referenced set only the
+// ids that appear in the credits of stored movies and shows are visited
+// instead of every number in the range, under a checkpoint of their own.
+func (m *PersonCrawler) Start(ctx context.Context, start int, end int, overwrite bool, referenced bool, opts FetchOptions) error {
+	if end == 0 {
+		end = m.maxID
+	}
+	c := crawl{
+		repo:      m.repo,
+		tp:        "person",
+		overwrite: overwrite,
+		exists: func(ctx context.Context, v int) (bool, error) {
+			return m.repo.ItemExists(ctx, "person", v)
+		},
+		fetch: func(ctx context.Context, v int) error {
+			return m.Fetch(ctx, v, opts)
+		},
+		checkpoint: m.repo.UpdatePersonProgress,
+	}
+	progress := m.GetPersonProgress
+	if referenced {
+		c.checkpoint = m.repo.UpdateReferencedPersonProgress
+		progress = m.repo.GetReferencedPersonProgress
+	}
+	if start == 0 {
+		index, err := progress(ctx)
+		if err != nil {
+			return err
+		}
+		start = index + 1
+	}
+	fmt.Println("Starting crawler for persons from index", start)
+
+	if !referenced {
+		return c.run(ctx, start-1, rangePages(end))
+	}
+	ids, err := m.repo.GetReferencedPersonIDs(ctx, start-1, end)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	fmt.Println("Found", len(ids), "referenced persons to crawl")
+	return c.run(ctx, start-1, listPages(ids))
+}
+
+func (m *PersonCrawler) GetPersonProgress(ctx context.Context) (int, error) {
+	return m.repo.GetPersonProgress(ctx)
+}
+
+// Fetch downloads a single person and stores it, recording it in not_found
+// or failed when that does not work. It does not touch the crawl progress.
+func (m *PersonCrawler) Fetch(ctx context.Context, v int, opts FetchOptions) error {
+	storeCtx := storeContext(ctx)
+
+	details, err := m.usecase.GetPersonDetails(ctx, fmt.Sprintf("%d", v), m.at)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Println("Error getting person details for", v)
+		if isNotFound(err) {
+			m.repo.InsertNotFound(storeCtx, v, "person")
+		} else {
+			m.repo.InsertError(storeCtx, v, "person", err)
+		}
+		return err
+	}
+
+	err = m.validator.Record(storeCtx, "person", v, m.validator.Person(v, details))
+	if err != nil {
+		return err
+	}
+
+	bt, err := json.Marshal(details)
+	if err != nil {
+		fmt.Printf("Error marshalling person data %d %v\n", v, err)
+		m.repo.InsertError(storeCtx, v, "person", err)
+		return err
+	}
+
+	err = m.repo.StoreDetails(storeCtx, v, bt, "person")
+	if err != nil {
+		fmt.Println("Error storing data in db")
+		m.repo.InsertError(storeCtx, v, "person", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ReferenceSyncer keeps the lookup data TMDB serves outside of the
+// per-item endpoints in sync: genres, countries, languages, the api
+// configuration and the certification rankings.
+type ReferenceSyncer struct {
+	usecase  *Usecase
+	at       string
+	repo     *Repo
+	interval time.Duration
+}
+
+func NewReferenceSyncer(usecase *Usecase, at string, repo *Repo, interval time.Duration) *ReferenceSyncer {
+	return &ReferenceSyncer{
+		usecase:  usecase,
+		at:       at,
+		repo:     repo,
+		interval: interval,
+	}
+}
+
+// Start syncs everything once and then again on every interval until ctx
+// is cancelled. A failed scheduled sync is retried on the next tick.
+func (s *ReferenceSyncer) Start(ctx context.Context) error {
+	if err := s.SyncOnce(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.SyncOnce(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				fmt.Println("Scheduled reference sync failed", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (s *ReferenceSyncer) SyncOnce(ctx context.Context) error {
+	for _, sync := range []func(context.Context) error{
+		s.SyncGenres,
+		s.SyncCountries,
+		s.SyncLanguages,
+		s.SyncConfiguration,
+		s.SyncCertifications,
+	} {
+		if err := sync(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ReferenceSyncer) SyncGenres(ctx context.Context) error {
+	for tp, tmdbType := range map[string]string{"movie": "movie", "show": "tv"} {
+		list, err := s.usecase.GetGenreList(ctx, tmdbType, s.at)
+		if err != nil {
+			return err
+		}
+		err = s.repo.StoreGenres(ctx, tp, list.Genres)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Genres stored for", tp)
+	}
+	return nil
+}
+
+func (s *ReferenceSyncer) SyncCountries(ctx context.Context) error {
+	countries, err := s.usecase.GetCountries(ctx, s.at)
+	if err != nil {
+		return err
+	}
+	err = s.repo.StoreCountries(ctx, countries)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Countries stored")
+	return nil
+}
+
+func (s *ReferenceSyncer) SyncLanguages(ctx context.Context) error {
+	languages, err := s.usecase.GetLanguages(ctx, s.at)
+	if err != nil {
+		return err
+	}
+	err = s.repo.StoreLanguages(ctx, languages)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Languages stored")
+	return nil
+}
+
+func (s *ReferenceSyncer) SyncConfiguration(ctx context.Context) error {
+	cfg, err := s.usecase.GetConfiguration(ctx, s.at)
+	if err != nil {
+		return err
+	}
+	err = s.repo.StoreConfiguration(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Configuration stored")
+	return nil
+}
+
+// SyncCertifications stores the per country certification ranking of
+// movies and shows.
+func (s *ReferenceSyncer) SyncCertifications(ctx context.Context) error {
+	for tp, tmdbType := range map[string]string{"movie": "movie", "show": "tv"} {
+		list, err := s.usecase.GetCertificationList(ctx, tmdbType, s.at)
+		if err != nil {
+			return err
+		}
+		err = s.repo.StoreCertificationOrder(ctx, tp, list)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Certification order stored for", tp)
+	}
+	return nil
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 )
 
 type Repo struct {
 	db *sql.DB
+	// replay stores items rebuilt from the archive, see Replay.
+	replay bool
 }
 
 func NewRepo(db *sql.DB) *Repo {
@@ -15,8 +19,19 @@ func NewRepo(db *sql.DB) *Repo {
 	}
 }
 
-func (r *Repo) CreateDb() error {
-	_, err := r.db.Exec(`create table if not exists details (
+// Replay returns a Repo for items rebuilt from archived responses. They
+// keep the time they were fetched at, and what only a live request tells,
+// the failures, the not found ids and the watch providers seen, is not
+// written.
+func (r *Repo) Replay() *Repo {
+	return &Repo{
+		db:     r.db,
+		replay: true,
+	}
+}
+
+func (r *Repo) CreateDb(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists details (
     id serial primary key,
     tmdb_id int not null,
     data jsonb not null,
@@ -27,7 +42,7 @@ func (r *Repo) CreateDb() error {
 		return err
 	}
 
-	_, err = r.db.Exec(`create table if not exists movie_progress (
+	_, err = r.db.ExecContext(ctx, `create table if not exists movie_progress (
     id serial primary key,
     progress int
     )`)
@@ -36,7 +51,7 @@ func (r *Repo) CreateDb() error {
 		return err
 	}
 
-	_, err = r.db.Exec(`create table if not exists show_progress (
+	_, err = r.db.ExecContext(ctx, `create table if not exists show_progress (
     id serial primary key,
     progress int
     )`)
@@ -45,7 +60,16 @@ func (r *Repo) CreateDb() error {
 		return err
 	}
 
-	_, err = r.db.Exec(`create table if not exists failed (
+	_, err = r.db.ExecContext(ctx, `create table if not exists person_progress (
+    id serial primary key,
+    progress int
+    )`)
+	if err != nil {
+		log.Println("Error creating person_progress table", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create table if not exists failed (
     id serial primary key,
     type varchar(10) not null,
     tmdb_id int not null,
@@ -56,7 +80,7 @@ func (r *Repo) CreateDb() error {
 		return err
 	}
 
-	_, err = r.db.Exec(`create table if not exists not_found (
+	_, err = r.db.ExecContext(ctx, `create table if not exists not_found (
     id serial primary key,
     type varchar(10) not null,
     tmdb_id int not null
@@ -66,21 +90,139 @@ func (r *Repo) CreateDb() error {
 		return err
 	}
 
+	// The upserts in StoreDetails and InsertNotFound rely on these.
+	_, err = r.db.ExecContext(ctx, `create unique index if not exists details_tmdb_id_type_key on details (tmdb_id, type)`)
+	if err != nil {
+		log.Println("Error creating details index", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create unique index if not exists not_found_tmdb_id_type_key on not_found (tmdb_id, type)`)
+	if err != nil {
+		log.Println("Error creating not_found index", err)
+		return err
+	}
+
+	// Rows stored before fetched_at existed have none and count as the
+	// stalest, see GetStaleItems.
+	_, err = r.db.ExecContext(ctx, `alter table details add column if not exists fetched_at timestamp`)
+	if err != nil {
+		log.Println("Error adding details fetched_at", err)
+		return err
+	}
+
+	// attempted_at is when the stale refresher last tried an item and
+	// failed, see GetStaleItems.
+	_, err = r.db.ExecContext(ctx, `alter table details add column if not exists attempted_at timestamp`)
+	if err != nil {
+		log.Println("Error adding details attempted_at", err)
+		return err
+	}
+
+	// Failures recorded before kind existed keep it empty.
+	_, err = r.db.ExecContext(ctx, `alter table failed add column if not exists kind varchar(20) not null default ''`)
+	if err != nil {
+		log.Println("Error adding failed kind", err)
+		return err
+	}
+
+	err = r.createCollectionTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createEpisodeTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createTranslationTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createCertificationTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createWatchProviderTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createKeywordTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createReferenceTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createCompanyTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createChartTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createCalendarTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createArchiveTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createDriftTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.createDataQualityTables(ctx)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (r *Repo) StoreDetails(id int, details []byte, tp string) error {
-	_, err := r.db.Exec(
-		`insert into details (tmdb_id, type, data) values($1, $2, $3) on conflict(tmdb_id, type) do update set data = excluded.data`,
+func (r *Repo) StoreDetails(ctx context.Context, id int, details []byte, tp string) error {
+	fetchedAt, err := r.archivedAt(ctx, detailsResource(tp, id))
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(
+		ctx,
+		`insert into details (tmdb_id, type, data, fetched_at) values($1, $2, $3, coalesce($4::timestamp, now()))
+    on conflict(tmdb_id, type) do update set data = excluded.data, fetched_at = excluded.fetched_at`,
 		id,
 		tp,
 		details,
+		fetchedAt,
 	)
 	return err
 }
 
-func (r *Repo) UpdateMovieProgress(progress int) error {
-	_, err := r.db.Exec(
+// detailsResource is the TMDB path the details of an item are fetched
+// from.
+func detailsResource(tp string, id int) string {
+	if tp == "show" {
+		tp = "tv"
+	}
+	return fmt.Sprintf("/%s/%d", tp, id)
+}
+
+func (r *Repo) UpdateMovieProgress(ctx context.Context, progress int) error {
+	_, err := r.db.ExecContext(
+		ctx,
 		`insert into movie_progress (id, progress) values($1, $2) on conflict (id) do update set progress = excluded.progress`,
 		1,
 		progress,
@@ -88,8 +230,9 @@ func (r *Repo) UpdateMovieProgress(progress int) error {
 	return err
 }
 
-func (r *Repo) UpdateShowProgress(progress int) error {
-	_, err := r.db.Exec(
+func (r *Repo) UpdateShowProgress(ctx context.Context, progress int) error {
+	_, err := r.db.ExecContext(
+		ctx,
 		`insert into show_progress (id, progress) values($1, $2) on conflict (id) do update set progress = excluded.progress`,
 		1,
 		progress,
@@ -97,12 +240,44 @@ func (r *Repo) UpdateShowProgress(progress int) error {
 	return err
 }
 
-func (r *Repo) InsertError(id int, tp string, er string) error {
-	_, err := r.db.Exec(
-		`insert into failed (tmdb_id, error, type) values($1, $2, $3)`,
+// The rows of person_progress. A crawl of the referenced persons only
+// keeps its own checkpoint so it doesn't move the one of the range crawl.
+const (
+	personRangeProgress      = 1
+	personReferencedProgress = 2
+)
+
+func (r *Repo) UpdatePersonProgress(ctx context.Context, progress int) error {
+	return r.updatePersonProgress(ctx, personRangeProgress, progress)
+}
+
+func (r *Repo) UpdateReferencedPersonProgress(ctx context.Context, progress int) error {
+	return r.updatePersonProgress(ctx, personReferencedProgress, progress)
+}
+
+func (r *Repo) updatePersonProgress(ctx context.Context, key int, progress int) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`insert into person_progress (id, progress) values($1, $2) on conflict (id) do update set progress = excluded.progress`,
+		key,
+		progress,
+	)
+	return err
+}
+
+// InsertError records a failure of an item with the kind of er, see
+// ErrorKind.
+func (r *Repo) InsertError(ctx context.Context, id int, tp string, er error) error {
+	if r.replay {
+		return nil
+	}
+	_, err := r.db.ExecContext(
+		ctx,
+		`insert into failed (tmdb_id, error, type, kind) values($1, $2, $3, $4)`,
 		id,
-		er,
+		er.Error(),
 		tp,
+		errorKind(er),
 	)
 	if err != nil {
 		log.Println("Error storing failed", err)
@@ -110,9 +285,9 @@ func (r *Repo) InsertError(id int, tp string, er string) error {
 	return err
 }
 
-func (r *Repo) GetMovieProgress() (int, error) {
+func (r *Repo) GetMovieProgress(ctx context.Context) (int, error) {
 	var res int
-	row := r.db.QueryRow(`select progress from movie_progress where id = 1`)
+	row := r.db.QueryRowContext(ctx, `select progress from movie_progress where id = 1`)
 	err := row.Scan(&res)
 	if err == sql.ErrNoRows {
 		return 0, nil
@@ -120,9 +295,9 @@ func (r *Repo) GetMovieProgress() (int, error) {
 	return res, err
 }
 
-func (r *Repo) GetShowProgress() (int, error) {
+func (r *Repo) GetShowProgress(ctx context.Context) (int, error) {
 	var res int
-	row := r.db.QueryRow(`select progress from show_progress where id = 1`)
+	row := r.db.QueryRowContext(ctx, `select progress from show_progress where id = 1`)
 	err := row.Scan(&res)
 	if err == sql.ErrNoRows {
 		return 0, nil
@@ -130,9 +305,28 @@ func (r *Repo) GetShowProgress() (int, error) {
 	return res, err
 }
 
-func (r *Repo) ItemExists(tp string, tmdbId int) (bool, error) {
+func (r *Repo) GetPersonProgress(ctx context.Context) (int, error) {
+	return r.getPersonProgress(ctx, personRangeProgress)
+}
+
+func (r *Repo) GetReferencedPersonProgress(ctx context.Context) (int, error) {
+	return r.getPersonProgress(ctx, personReferencedProgress)
+}
+
+func (r *Repo) getPersonProgress(ctx context.Context, key int) (int, error) {
 	var res int
-	row := r.db.QueryRow(
+	row := r.db.QueryRowContext(ctx, `select progress from person_progress where id = $1`, key)
+	err := row.Scan(&res)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return res, err
+}
+
+func (r *Repo) ItemExists(ctx context.Context, tp string, tmdbId int) (bool, error) {
+	var res int
+	row := r.db.QueryRowContext(
+		ctx,
 		`select count(*) from details where type = $1 and tmdb_id = $2`,
 		tp,
 		tmdbId,
@@ -144,9 +338,10 @@ func (r *Repo) ItemExists(tp string, tmdbId int) (bool, error) {
 	return res != 0, err
 }
 
-func (r *Repo) NotFoundExists(tp string, tmdbId int) (bool, error) {
+func (r *Repo) NotFoundExists(ctx context.Context, tp string, tmdbId int) (bool, error) {
 	var res int
-	row := r.db.QueryRow(
+	row := r.db.QueryRowContext(
+		ctx,
 		`select count(*) from not_found where type = $1 and tmdb_id = $2`,
 		tp,
 		tmdbId,
@@ -158,8 +353,12 @@ func (r *Repo) NotFoundExists(tp string, tmdbId int) (bool, error) {
 	return res != 0, err
 }
 
-func (r *Repo) InsertNotFound(id int, tp string) error {
-	_, err := r.db.Exec(
+func (r *Repo) InsertNotFound(ctx context.Context, id int, tp string) error {
+	if r.replay {
+		return nil
+	}
+	_, err := r.db.ExecContext(
+		ctx,
 		`insert into not_found (tmdb_id, type) values($1, $2) on conflict (tmdb_id, type) do nothing`,
 		id,
 		tp,
@@ -169,3 +368,176 @@ func (r *Repo) InsertNotFound(id int, tp string) error {
 	}
 	return err
 }
+
+func (r *Repo) GetDetails(ctx context.Context, tp string, tmdbId int) ([]byte, error) {
+	var res []byte
+	row := r.db.QueryRowContext(
+		ctx,
+		`select data from details where type = $1 and tmdb_id = $2`,
+		tp,
+		tmdbId,
+	)
+	err := row.Scan(&res)
+	return res, err
+}
+
+// GetFailedIDs returns every distinct id of the given type that has a
+// failure recorded, lowest first. A kind only returns the ids whose latest
+// failure is of that kind.
+func (r *Repo) GetFailedIDs(ctx context.Context, tp string, kind ErrorKind) ([]int, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select tmdb_id from (
+    select distinct on (tmdb_id) tmdb_id, kind from failed where type = $1 order by tmdb_id, id desc
+    ) latest where $2 = '' or kind = $2 order by tmdb_id`,
+		tp,
+		kind,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, rows.Err()
+}
+
+type FailedCount struct {
+	Type  string `json:"type"`
+	Kind  string `json:"kind"`
+	Items int    `json:"items"`
+}
+
+// GetFailedCounts counts the failed items by type and the kind of their
+// latest failure, of one type when tp is set.
+func (r *Repo) GetFailedCounts(ctx context.Context, tp string) ([]FailedCount, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select type, kind, count(*) from (
+    select distinct on (type, tmdb_id) type, kind from failed where $1 = '' or type = $1 order by type, tmdb_id, id desc
+    ) latest group by type, kind order by type, kind`,
+		tp,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []FailedCount{}
+	for rows.Next() {
+		var c FailedCount
+		if err := rows.Scan(&c.Type, &c.Kind, &c.Items); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+// LatestFailedID returns the id of the newest failure recorded for an
+// item, 0 when there is none.
+func (r *Repo) LatestFailedID(ctx context.Context, tp string, tmdbId int) (int, error) {
+	var res int
+	row := r.db.QueryRowContext(
+		ctx,
+		`select coalesce(max(id), 0) from failed where type = $1 and tmdb_id = $2`,
+		tp,
+		tmdbId,
+	)
+	err := row.Scan(&res)
+	return res, err
+}
+
+// ClearFailed removes the failures of an item up to and including the one
+// with id upTo, so failures recorded by a retry that started after it are
+// kept. It returns how many failures the item has left.
+func (r *Repo) ClearFailed(ctx context.Context, tp string, tmdbId int, upTo int) (int, error) {
+	_, err := r.db.ExecContext(
+		ctx,
+		`delete from failed where type = $1 and tmdb_id = $2 and id <= $3`,
+		tp,
+		tmdbId,
+		upTo,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var left int
+	row := r.db.QueryRowContext(ctx, `select count(*) from failed where type = $1 and tmdb_id = $2`, tp, tmdbId)
+	err = row.Scan(&left)
+	return left, err
+}
+
+// ExportDetails streams every stored item of the given type, or of every
+// type when tp is empty, to fn in tmdb_id order.
+func (r *Repo) ExportDetails(ctx context.Context, tp string, fn func(id int, tp string, data []byte) error) error {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select tmdb_id, type, data from details where $1 = '' or type = $1 order by type, tmdb_id`,
+		tp,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int
+			t    string
+			data []byte
+		)
+		if err := rows.Scan(&id, &t, &data); err != nil {
+			return err
+		}
+		if err := fn(id, t, data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// jsonArray guards jsonb_array_elements against missing or null arrays.
+func jsonArray(expr string) string {
+	return fmt.Sprintf(`case jsonb_typeof(%s) when 'array' then %s else '[]'::jsonb end`, expr, expr)
+}
+
+// GetReferencedPersonIDs returns the distinct person ids in (after, end]
+// that appear in the cast, crew or created_by of stored movies and shows,
+// lowest first. They are loaded in one go, the credits are scanned once
+// per crawl instead of once per page.
+func (r *Repo) GetReferencedPersonIDs(ctx context.Context, after int, end int) ([]int, error) {
+	query := fmt.Sprintf(`select id from (
+    select (c->>'id')::int as id from details d, jsonb_array_elements(%s) c where d.type in ('movie', 'show')
+    union
+    select (c->>'id')::int from details d, jsonb_array_elements(%s) c where d.type in ('movie', 'show')
+    union
+    select (c->>'id')::int from details d, jsonb_array_elements(%s) c where d.type = 'show'
+    ) ids where id > $1 and id <= $2 order by id`,
+		jsonArray(`d.data->'credits'->'cast'`),
+		jsonArray(`d.data->'credits'->'crew'`),
+		jsonArray(`d.data->'created_by'`),
+	)
+	rows, err := r.db.QueryContext(ctx, query, after, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, rows.Err()
+}
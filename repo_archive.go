@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+)
+
+func (r *Repo) createArchiveTables(ctx context.Context) error {
+	// path is the request path and query below the api base url, body the
+	// gzipped response body.
+	_, err := r.db.ExecContext(ctx, `create table if not exists raw_responses (
+    id serial primary key,
+    path text not null,
+    status int not null,
+    body bytea not null,
+    fetched_at timestamp not null default now()
+    )`)
+	if err != nil {
+		log.Println("Error creating raw_responses table", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create index if not exists raw_responses_path_idx
+    on raw_responses (path, fetched_at desc)`)
+	if err != nil {
+		log.Println("Error creating raw_responses index", err)
+		return err
+	}
+
+	// resource is path without append_to_response, see archiveResource.
+	// Responses archived before it existed get it filled in the same way,
+	// once, when the column is added.
+	_, err = r.db.ExecContext(ctx, `do $$ begin
+    if not exists (select 1 from information_schema.columns
+    where table_name = 'raw_responses' and column_name = 'resource') then
+    alter table raw_responses add column resource text;
+    update raw_responses set resource = split_part(path, '?', 1) || coalesce('?' || nullif(array_to_string(array(
+    select p from unnest(string_to_array(split_part(path, '?', 2), '&')) with ordinality as q(p, n)
+    where p <> '' and p not like 'append\_to\_response=%' order by n
+    ), '&'), ''), '');
+    end if;
+    end $$`)
+	if err != nil {
+		log.Println("Error adding raw_responses resource", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create index if not exists raw_responses_resource_idx
+    on raw_responses (resource, fetched_at desc)`)
+	if err != nil {
+		log.Println("Error creating raw_responses resource index", err)
+		return err
+	}
+	return nil
+}
+
+// archiveResource splits path into the resource it asks for and the sub
+// requests it appends. The rest of the query is part of the resource:
+// /movie/550?append_to_response=credits,images is /movie/550 appending
+// credits and images, /movie/550/reviews?page=2 stays as it is.
+func archiveResource(path string) (string, []string) {
+	resource, query, _ := strings.Cut(path, "?")
+	var rest, appends []string
+	for _, p := range strings.Split(query, "&") {
+		if p == "" {
+			continue
+		}
+		if keys, ok := strings.CutPrefix(p, "append_to_response="); ok {
+			appends = append(appends, strings.Split(keys, ",")...)
+			continue
+		}
+		rest = append(rest, p)
+	}
+	if len(rest) > 0 {
+		resource += "?" + strings.Join(rest, "&")
+	}
+	return resource, appends
+}
+
+// StoreRawResponse archives a response body gzipped.
+func (r *Repo) StoreRawResponse(ctx context.Context, path string, status int, body []byte) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	resource, _ := archiveResource(path)
+	_, err := r.db.ExecContext(
+		ctx,
+		`insert into raw_responses (path, resource, status, body) values($1, $2, $3, $4)`,
+		path,
+		resource,
+		status,
+		buf.Bytes(),
+	)
+	return err
+}
+
+// LoadRawResponse answers path from the archived responses of its
+// resource, so a request appending other sub requests than the archived
+// ones still finds them. The newest response gives the status and the
+// item itself, every appended sub request comes from the newest response
+// that asked for it and is left out when none did. It returns
+// sql.ErrNoRows if the resource was never archived.
+func (r *Repo) LoadRawResponse(ctx context.Context, path string) (int, []byte, error) {
+	resource, appends := archiveResource(path)
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select path, status, body from raw_responses where resource = $1 order by fetched_at desc, id desc`,
+		resource,
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var merged map[string]json.RawMessage
+	wanted := slices.Clone(appends)
+	for rows.Next() && (merged == nil || len(wanted) > 0) {
+		var (
+			archivedPath string
+			status       int
+			data         []byte
+		)
+		if err := rows.Scan(&archivedPath, &status, &data); err != nil {
+			return 0, nil, err
+		}
+		if merged == nil && status != 200 {
+			// Not there anymore the last time it was asked for.
+			body, err := gunzip(archivedPath, data)
+			return status, body, err
+		}
+		if status != 200 {
+			continue
+		}
+
+		body, err := gunzip(archivedPath, data)
+		if err != nil {
+			return 0, nil, err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return 0, nil, fmt.Errorf("archived %s: %w", archivedPath, err)
+		}
+		if merged == nil {
+			merged = make(map[string]json.RawMessage, len(fields))
+			for k, v := range fields {
+				merged[k] = v
+			}
+			// Only what this response appended counts for the sub
+			// requests, the rest may be plain fields of the item.
+			for _, k := range wanted {
+				delete(merged, k)
+			}
+		}
+		_, archivedAppends := archiveResource(archivedPath)
+		wanted = slices.DeleteFunc(wanted, func(k string) bool {
+			if !slices.Contains(archivedAppends, k) {
+				return false
+			}
+			if v, ok := fields[k]; ok {
+				merged[k] = v
+			}
+			return true
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	if merged == nil {
+		return 0, nil, sql.ErrNoRows
+	}
+
+	body, err := json.Marshal(merged)
+	if err != nil {
+		return 0, nil, err
+	}
+	return 200, body, nil
+}
+
+func gunzip(path string, data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("archived %s: %w", path, err)
+	}
+	defer zr.Close()
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("archived %s: %w", path, err)
+	}
+	return body, nil
+}
+
+// archivedAt is the fetched_at of a row built from resource: null, which
+// the stores turn into now(), or when replaying the time the newest
+// archived answer of resource was fetched.
+func (r *Repo) archivedAt(ctx context.Context, resource string) (sql.NullTime, error) {
+	var res sql.NullTime
+	if !r.replay {
+		return res, nil
+	}
+	err := r.db.QueryRowContext(
+		ctx,
+		`select max(fetched_at) from raw_responses where resource = $1 and status = 200`,
+		resource,
+	).Scan(&res)
+	return res, err
+}
+
+// GetArchivedIDs returns up to limit ids greater than after that have an
+// archived details response under /{tmdbType}/{id}, lowest first.
+func (r *Repo) GetArchivedIDs(ctx context.Context, tmdbType string, after int, limit int) ([]int, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select distinct id from (
+    select case when split_part(p, '/', 3) ~ '^[0-9]{1,9}$' then split_part(p, '/', 3)::int end as id
+    from (select split_part(path, '?', 1) as p from raw_responses) paths
+    where split_part(p, '/', 2) = $1 and split_part(p, '/', 4) = ''
+    ) ids where id > $2 order by id limit $3`,
+		tmdbType,
+		after,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArchiveResource(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		resource string
+		appends  []string
+	}{
+		{"plain", "/movie/550", "/movie/550", nil},
+		{"appends", "/movie/550?append_to_response=credits,watch/providers", "/movie/550", []string{"credits", "watch/providers"}},
+		{"season batch", "/tv/1399?append_to_response=season/1,season/2", "/tv/1399", []string{"season/1", "season/2"}},
+		{"query kept", "/movie/550/reviews?page=2", "/movie/550/reviews?page=2", nil},
+		{"query around appends", "/discover/movie?page=3&append_to_response=credits&sort_by=id", "/discover/movie?page=3&sort_by=id", []string{"credits"}},
+		{"empty query", "/configuration?", "/configuration", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, appends := archiveResource(tt.path)
+			if resource != tt.resource {
+				t.Errorf("resource = %q, want %q", resource, tt.resource)
+			}
+			if !reflect.DeepEqual(appends, tt.appends) {
+				t.Errorf("appends = %v, want %v", appends, tt.appends)
+			}
+		})
+	}
+}
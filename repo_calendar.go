@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// calendarKeep is how far back aired episodes stay in upcoming_episodes so
+// the calendar still shows the last few days.
+const calendarKeep = "7 days"
+
+func (r *Repo) createCalendarTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists upcoming_episodes (
+    show_id int not null,
+    season_number int not null,
+    episode_number int not null,
+    air_date date not null,
+    show_name text not null,
+    name text not null,
+    overview text not null,
+    runtime int,
+    network_ids int[] not null,
+    primary key (show_id, season_number, episode_number)
+    )`)
+	if err != nil {
+		log.Println("Error creating upcoming_episodes table", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create index if not exists upcoming_episodes_air_date_idx
+    on upcoming_episodes (air_date)`)
+	if err != nil {
+		log.Println("Error creating upcoming_episodes index", err)
+		return err
+	}
+	return nil
+}
+
+// upcomingEpisodesQuery selects the recent and future episodes of the
+// stored shows matching cond from their seasons and next_episode_to_air.
+var upcomingEpisodesQuery = fmt.Sprintf(`select show_id, season_number, episode_number, air_date, show_name, name, overview, runtime, network_ids
+    from (
+    select d.tmdb_id as show_id,
+    (e->>'season_number')::int as season_number,
+    (e->>'episode_number')::int as episode_number,
+    nullif(e->>'air_date', '')::date as air_date,
+    coalesce(d.data->>'name', '') as show_name,
+    coalesce(e->>'name', '') as name,
+    coalesce(e->>'overview', '') as overview,
+    (e->>'runtime')::int as runtime,
+    array(select (n->>'id')::int from jsonb_array_elements(%s) n) as network_ids
+    from details d,
+    lateral (
+    select e from jsonb_array_elements(%s) s, jsonb_array_elements(%s) e
+    union all
+    select d.data->'next_episode_to_air' where jsonb_typeof(d.data->'next_episode_to_air') = 'object'
+    ) eps(e)
+    where d.type = 'show' and %%s
+    ) x
+    where air_date >= current_date - interval '%s'`,
+	jsonArray(`d.data->'networks'`),
+	jsonArray(`d.data->'seasons'`),
+	jsonArray(`s->'episodes'`),
+	calendarKeep,
+)
+
+// StoreUpcomingEpisodes rebuilds the calendar entries of a stored show.
+func (r *Repo) StoreUpcomingEpisodes(ctx context.Context, showID int) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(ctx, `delete from upcoming_episodes where show_id = $1`, showID)
+	if err != nil {
+		return err
+	}
+	_, err = txn.ExecContext(
+		ctx,
+		`insert into upcoming_episodes (show_id, season_number, episode_number, air_date, show_name, name, overview, runtime, network_ids) `+
+			fmt.Sprintf(upcomingEpisodesQuery, "d.tmdb_id = $1")+
+			// An episode can be both in its season and next_episode_to_air.
+			` on conflict (show_id, season_number, episode_number) do nothing`,
+		showID,
+	)
+	if err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// RebuildUpcomingEpisodes rebuilds the calendar from every stored show.
+func (r *Repo) RebuildUpcomingEpisodes(ctx context.Context) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(ctx, `delete from upcoming_episodes`)
+	if err != nil {
+		return err
+	}
+	_, err = txn.ExecContext(
+		ctx,
+		`insert into upcoming_episodes (show_id, season_number, episode_number, air_date, show_name, name, overview, runtime, network_ids) `+
+			fmt.Sprintf(upcomingEpisodesQuery, "true")+
+			` on conflict (show_id, season_number, episode_number) do nothing`,
+	)
+	if err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+type CalendarEntry struct {
+	ShowID        int       `json:"show_id"`
+	ShowName      string    `json:"show_name"`
+	SeasonNumber  int       `json:"season_number"`
+	EpisodeNumber int       `json:"episode_number"`
+	Name          string    `json:"name"`
+	Overview      string    `json:"overview"`
+	AirDate       time.Time `json:"-"`
+	Date          string    `json:"air_date"`
+	Runtime       *int      `json:"runtime,omitempty"`
+	NetworkIDs    []int64   `json:"network_ids"`
+}
+
+// CalendarFilter selects episodes airing between From and To, inclusive.
+// ShowIDs and NetworkIDs narrow it down when set.
+type CalendarFilter struct {
+	From       time.Time
+	To         time.Time
+	ShowIDs    []int64
+	NetworkIDs []int64
+}
+
+func (r *Repo) GetCalendar(ctx context.Context, f CalendarFilter) ([]CalendarEntry, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select show_id, show_name, season_number, episode_number, name, overview, air_date, runtime, network_ids
+    from upcoming_episodes
+    where air_date between $1 and $2
+    and (cardinality($3::int[]) = 0 or show_id = any($3))
+    and (cardinality($4::int[]) = 0 or network_ids && $4::int[])
+    order by air_date, show_name, season_number, episode_number`,
+		f.From,
+		f.To,
+		pq.Array(f.ShowIDs),
+		pq.Array(f.NetworkIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []CalendarEntry{}
+	for rows.Next() {
+		var e CalendarEntry
+		err := rows.Scan(
+			&e.ShowID,
+			&e.ShowName,
+			&e.SeasonNumber,
+			&e.EpisodeNumber,
+			&e.Name,
+			&e.Overview,
+			&e.AirDate,
+			&e.Runtime,
+			pq.Array(&e.NetworkIDs),
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.Date = e.AirDate.Format(time.DateOnly)
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
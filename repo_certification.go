@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"log"
+	"tmdb_scraper/models"
+)
+
+func (r *Repo) createCertificationTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists certifications (
+    type varchar(10) not null,
+    tmdb_id int not null,
+    iso_3166_1 varchar(2) not null,
+    certification varchar(20) not null,
+    primary key (type, tmdb_id, iso_3166_1)
+    )`)
+	if err != nil {
+		log.Println("Error creating certifications table", err)
+		return err
+	}
+
+	// certification_order ranks the certifications of a country from the
+	// most to the least permissive, it backs the certification_lte filter.
+	_, err = r.db.ExecContext(ctx, `create table if not exists certification_order (
+    type varchar(10) not null,
+    iso_3166_1 varchar(2) not null,
+    certification varchar(20) not null,
+    meaning text not null,
+    ord int not null,
+    primary key (type, iso_3166_1, certification)
+    )`)
+	if err != nil {
+		log.Println("Error creating certification_order table", err)
+		return err
+	}
+	return nil
+}
+
+// StoreCertifications replaces the per country certifications of an item.
+func (r *Repo) StoreCertifications(ctx context.Context, tp string, tmdbId int, certs map[string]string) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(
+		ctx,
+		`delete from certifications where type = $1 and tmdb_id = $2`,
+		tp,
+		tmdbId,
+	)
+	if err != nil {
+		return err
+	}
+
+	for country, cert := range certs {
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into certifications (type, tmdb_id, iso_3166_1, certification) values($1, $2, $3, $4)`,
+			tp,
+			tmdbId,
+			country,
+			cert,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return txn.Commit()
+}
+
+// StoreCertificationOrder replaces the certification ranking of a type.
+func (r *Repo) StoreCertificationOrder(ctx context.Context, tp string, list models.CertificationList) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(ctx, `delete from certification_order where type = $1`, tp)
+	if err != nil {
+		return err
+	}
+
+	for country, certs := range list.Certifications {
+		for _, c := range certs {
+			_, err = txn.ExecContext(
+				ctx,
+				`insert into certification_order (type, iso_3166_1, certification, meaning, ord) values($1, $2, $3, $4, $5)
+    on conflict (type, iso_3166_1, certification) do update set meaning = excluded.meaning, ord = excluded.ord`,
+				tp,
+				country,
+				c.Certification,
+				c.Meaning,
+				c.Order,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return txn.Commit()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+func (r *Repo) createChartTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists chart_entries (
+    chart varchar(30) not null,
+    snapshot_date date not null,
+    rank int not null,
+    type varchar(10) not null,
+    tmdb_id int not null,
+    title text not null,
+    popularity float not null,
+    primary key (chart, snapshot_date, rank)
+    )`)
+	if err != nil {
+		log.Println("Error creating chart_entries table", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create index if not exists chart_entries_item_idx
+    on chart_entries (type, tmdb_id, snapshot_date)`)
+	if err != nil {
+		log.Println("Error creating chart_entries index", err)
+		return err
+	}
+	return nil
+}
+
+// StoreChartSnapshot replaces the snapshot of a chart for date.
+func (r *Repo) StoreChartSnapshot(ctx context.Context, chart string, date time.Time, rows []ChartRow) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(
+		ctx,
+		`delete from chart_entries where chart = $1 and snapshot_date = $2`,
+		chart,
+		date,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into chart_entries (chart, snapshot_date, rank, type, tmdb_id, title, popularity)
+    values($1, $2, $3, $4, $5, $6, $7)`,
+			chart,
+			date,
+			row.Rank,
+			row.Type,
+			row.ID,
+			row.Title,
+			row.Popularity,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return txn.Commit()
+}
+
+// GetChart returns the latest snapshot of a chart taken on or before date
+// and its date, sql.ErrNoRows if there is none.
+func (r *Repo) GetChart(ctx context.Context, chart string, date time.Time) (time.Time, []ChartRow, error) {
+	var (
+		snapshot time.Time
+		latest   sql.NullTime
+	)
+	err := r.db.QueryRowContext(
+		ctx,
+		`select max(snapshot_date) from chart_entries where chart = $1 and snapshot_date <= $2`,
+		chart,
+		date,
+	).Scan(&latest)
+	if err != nil {
+		return snapshot, nil, err
+	}
+	if !latest.Valid {
+		return snapshot, nil, sql.ErrNoRows
+	}
+	snapshot = latest.Time
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select rank, type, tmdb_id, title, popularity from chart_entries
+    where chart = $1 and snapshot_date = $2 order by rank`,
+		chart,
+		snapshot,
+	)
+	if err != nil {
+		return snapshot, nil, err
+	}
+	defer rows.Close()
+
+	res := []ChartRow{}
+	for rows.Next() {
+		var c ChartRow
+		if err := rows.Scan(&c.Rank, &c.Type, &c.ID, &c.Title, &c.Popularity); err != nil {
+			return snapshot, nil, err
+		}
+		res = append(res, c)
+	}
+	return snapshot, res, rows.Err()
+}
+
+type ChartPosition struct {
+	Chart string `json:"chart"`
+	Date  string `json:"date"`
+	Rank  int    `json:"rank"`
+}
+
+// GetChartPositions returns where an item was ranked between from and to,
+// on every chart or only on chart when it is set.
+func (r *Repo) GetChartPositions(ctx context.Context, tp string, tmdbId int, chart string, from time.Time, to time.Time) ([]ChartPosition, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select chart, to_char(snapshot_date, 'YYYY-MM-DD'), rank from chart_entries
+    where type = $1 and tmdb_id = $2 and ($3 = '' or chart = $3)
+    and snapshot_date between $4 and $5
+    order by chart, snapshot_date`,
+		tp,
+		tmdbId,
+		chart,
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []ChartPosition{}
+	for rows.Next() {
+		var p ChartPosition
+		if err := rows.Scan(&p.Chart, &p.Date, &p.Rank); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
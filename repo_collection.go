@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+func (r *Repo) createCollectionTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists collections (
+    tmdb_id int primary key,
+    data jsonb not null,
+    fetched_at timestamp not null default now()
+    )`)
+	if err != nil {
+		log.Println("Error creating collections table", err)
+		return err
+	}
+
+	// fetched_at was timestamptz at first, it is a timestamp like in the
+	// other tables.
+	_, err = r.db.ExecContext(ctx, `do $$ begin
+    if (select data_type from information_schema.columns
+    where table_name = 'collections' and column_name = 'fetched_at') = 'timestamp with time zone' then
+    alter table collections alter column fetched_at type timestamp;
+    end if;
+    end $$`)
+	if err != nil {
+		log.Println("Error changing collections fetched_at", err)
+		return err
+	}
+	return nil
+}
+
+func (r *Repo) StoreCollection(ctx context.Context, id int64, data []byte) error {
+	fetchedAt, err := r.archivedAt(ctx, fmt.Sprintf("/collection/%d", id))
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(
+		ctx,
+		`insert into collections (tmdb_id, data, fetched_at) values($1, $2, coalesce($3::timestamp, now()))
+    on conflict (tmdb_id) do update set data = excluded.data, fetched_at = excluded.fetched_at`,
+		id,
+		data,
+		fetchedAt,
+	)
+	return err
+}
+
+func (r *Repo) GetCollection(ctx context.Context, id int64) ([]byte, error) {
+	var res []byte
+	row := r.db.QueryRowContext(ctx, `select data from collections where tmdb_id = $1`, id)
+	err := row.Scan(&res)
+	return res, err
+}
+
+// CollectionFetchedAt returns when a collection was last stored, or nil if
+// it never was.
+func (r *Repo) CollectionFetchedAt(ctx context.Context, id int64) (*time.Time, error) {
+	var res time.Time
+	// As timestamptz it comes back as the instant it is in the session's
+	// time zone.
+	row := r.db.QueryRowContext(ctx, `select fetched_at::timestamptz from collections where tmdb_id = $1`, id)
+	err := row.Scan(&res)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// orgTables maps the company and network types to their tables.
+var orgTables = map[string]string{
+	"company": "companies",
+	"network": "networks",
+}
+
+func (r *Repo) createCompanyTables(ctx context.Context) error {
+	for _, table := range orgTables {
+		_, err := r.db.ExecContext(ctx, fmt.Sprintf(`create table if not exists %s (
+    tmdb_id int primary key,
+    data jsonb not null,
+    fetched_at timestamp not null default now()
+    )`, table))
+		if err != nil {
+			log.Println("Error creating", table, "table", err)
+			return err
+		}
+	}
+
+	// ListOrgTitles looks titles up by containment on these arrays.
+	_, err := r.db.ExecContext(ctx, `create index if not exists details_production_companies_idx
+    on details using gin ((data->'production_companies') jsonb_path_ops)`)
+	if err != nil {
+		log.Println("Error creating details production companies index", err)
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `create index if not exists details_networks_idx
+    on details using gin ((data->'networks') jsonb_path_ops)`)
+	if err != nil {
+		log.Println("Error creating details networks index", err)
+		return err
+	}
+	return nil
+}
+
+// StoreOrg stores a company or network, tp is company or network.
+func (r *Repo) StoreOrg(ctx context.Context, tp string, tmdbId int, data []byte) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		fmt.Sprintf(`insert into %s (tmdb_id, data, fetched_at) values($1, $2, now())
+    on conflict (tmdb_id) do update set data = excluded.data, fetched_at = excluded.fetched_at`, orgTables[tp]),
+		tmdbId,
+		data,
+	)
+	return err
+}
+
+func (r *Repo) OrgExists(ctx context.Context, tp string, tmdbId int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(
+		ctx,
+		fmt.Sprintf(`select exists(select 1 from %s where tmdb_id = $1)`, orgTables[tp]),
+		tmdbId,
+	).Scan(&exists)
+	return exists, err
+}
+
+// GetOrg returns the stored company or network, sql.ErrNoRows if there is
+// none.
+func (r *Repo) GetOrg(ctx context.Context, tp string, tmdbId int) ([]byte, error) {
+	var data []byte
+	err := r.db.QueryRowContext(
+		ctx,
+		fmt.Sprintf(`select data from %s where tmdb_id = $1`, orgTables[tp]),
+		tmdbId,
+	).Scan(&data)
+	return data, err
+}
+
+// orgArray is where stored details list the companies or networks.
+func orgArray(tp string) (string, string) {
+	if tp == "network" {
+		return `d.data->'networks'`, `d.type = 'show'`
+	}
+	return `d.data->'production_companies'`, `d.type in ('movie', 'show')`
+}
+
+// GetReferencedOrgIDs returns the distinct company or network ids that
+// appear in stored movies and shows, lowest first, all at once like
+// GetReferencedPersonIDs.
+func (r *Repo) GetReferencedOrgIDs(ctx context.Context, tp string) ([]int, error) {
+	array, types := orgArray(tp)
+	query := fmt.Sprintf(`select distinct (c->>'id')::int as id
+    from details d, jsonb_array_elements(%s) c
+    where %s
+    order by id`,
+		jsonArray(array),
+		types,
+	)
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, rows.Err()
+}
+
+// ListOrgTitles lists the stored movies and shows of a company, or the
+// shows of a network.
+func (r *Repo) ListOrgTitles(ctx context.Context, tp string, tmdbId int, limit int, offset int) ([]DetailSummary, error) {
+	array, types := orgArray(tp)
+	query := fmt.Sprintf(`select d.type, d.tmdb_id,
+    coalesce(d.data->>'title', d.data->>'name', ''),
+    coalesce(d.data->>'release_date', d.data->>'first_air_date', ''),
+    coalesce((d.data->>'popularity')::float, 0)
+    from details d
+    where %s and %s @> jsonb_build_array(jsonb_build_object('id', $1::int))
+    order by coalesce((d.data->>'popularity')::float, 0) desc, d.type, d.tmdb_id
+    limit $2 offset $3`,
+		types,
+		array,
+	)
+	rows, err := r.db.QueryContext(ctx, query, tmdbId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []DetailSummary{}
+	for rows.Next() {
+		var s DetailSummary
+		if err := rows.Scan(&s.Type, &s.ID, &s.Title, &s.Date, &s.Popularity); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+func (r *Repo) createDataQualityTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists data_quality (
+    type varchar(10) not null,
+    tmdb_id int not null,
+    rule varchar(30) not null,
+    message text not null,
+    checked_at timestamp not null default now(),
+    primary key (type, tmdb_id, rule)
+    )`)
+	if err != nil {
+		log.Println("Error creating data_quality table", err)
+		return err
+	}
+	return nil
+}
+
+// StoreViolations replaces the violations of an item, so the ones fixed by
+// a later fetch go away.
+func (r *Repo) StoreViolations(ctx context.Context, tp string, id int, violations []Violation) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(ctx, `delete from data_quality where type = $1 and tmdb_id = $2`, tp, id)
+	if err != nil {
+		return err
+	}
+	for _, v := range violations {
+		// A rule can be broken more than once, like two empty fields.
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into data_quality (type, tmdb_id, rule, message) values($1, $2, $3, $4)
+    on conflict (type, tmdb_id, rule) do update set message = data_quality.message || '; ' || excluded.message`,
+			tp,
+			id,
+			v.Rule,
+			v.Message,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+type DataQualityIssue struct {
+	Type   string `json:"type"`
+	TmdbID int    `json:"tmdb_id"`
+	Violation
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// GetViolations lists the recorded violations, filtered by type and rule
+// when they are set, newest first.
+func (r *Repo) GetViolations(ctx context.Context, tp string, rule string, limit int, offset int) ([]DataQualityIssue, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select type, tmdb_id, rule, message, checked_at from data_quality
+    where ($1 = '' or type = $1) and ($2 = '' or rule = $2)
+    order by checked_at desc, type, tmdb_id limit $3 offset $4`,
+		tp,
+		rule,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []DataQualityIssue{}
+	for rows.Next() {
+		var d DataQualityIssue
+		err := rows.Scan(&d.Type, &d.TmdbID, &d.Rule, &d.Message, &d.CheckedAt)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+func (r *Repo) createDriftTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists schema_drift (
+    endpoint varchar(20) not null,
+    path text not null,
+    kind varchar(20) not null,
+    json_type varchar(10) not null,
+    expected text not null,
+    occurrences bigint not null default 1,
+    first_seen timestamp not null default now(),
+    last_seen timestamp not null default now(),
+    primary key (endpoint, path, kind)
+    )`)
+	if err != nil {
+		log.Println("Error creating schema_drift table", err)
+		return err
+	}
+	return nil
+}
+
+// StoreDrift adds the issues found in one payload of endpoint to the
+// drift report.
+func (r *Repo) StoreDrift(ctx context.Context, endpoint string, issues []DriftIssue) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	for _, issue := range issues {
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into schema_drift (endpoint, path, kind, json_type, expected) values($1, $2, $3, $4, $5)
+    on conflict (endpoint, path, kind) do update set
+    json_type = excluded.json_type,
+    expected = excluded.expected,
+    occurrences = schema_drift.occurrences + 1,
+    last_seen = now()`,
+			endpoint,
+			issue.Path,
+			issue.Kind,
+			issue.JSONType,
+			issue.Expected,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+type DriftReport struct {
+	Endpoint string `json:"endpoint"`
+	DriftIssue
+	Occurrences int64     `json:"occurrences"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// GetDrift returns the drift report, of one endpoint when it is set, the
+// most recently seen first.
+func (r *Repo) GetDrift(ctx context.Context, endpoint string) ([]DriftReport, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select endpoint, path, kind, json_type, expected, occurrences, first_seen, last_seen
+    from schema_drift where $1 = '' or endpoint = $1
+    order by last_seen desc, endpoint, path`,
+		endpoint,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []DriftReport{}
+	for rows.Next() {
+		var d DriftReport
+		err := rows.Scan(&d.Endpoint, &d.Path, &d.Kind, &d.JSONType, &d.Expected, &d.Occurrences, &d.FirstSeen, &d.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
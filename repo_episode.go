@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+func (r *Repo) createEpisodeTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists episodes (
+    show_id int not null,
+    season_number int not null,
+    episode_number int not null,
+    tmdb_id int not null,
+    data jsonb not null,
+    fetched_at timestamp not null default now(),
+    primary key (show_id, season_number, episode_number)
+    )`)
+	if err != nil {
+		log.Println("Error creating episodes table", err)
+		return err
+	}
+
+	// Like in collections fetched_at started out as timestamptz.
+	_, err = r.db.ExecContext(ctx, `do $$ begin
+    if (select data_type from information_schema.columns
+    where table_name = 'episodes' and column_name = 'fetched_at') = 'timestamp with time zone' then
+    alter table episodes alter column fetched_at type timestamp;
+    end if;
+    end $$`)
+	if err != nil {
+		log.Println("Error changing episodes fetched_at", err)
+		return err
+	}
+	return nil
+}
+
+func (r *Repo) StoreEpisode(ctx context.Context, showID int, season int64, episode int64, tmdbID int64, data []byte) error {
+	fetchedAt, err := r.archivedAt(ctx, fmt.Sprintf("/tv/%d/season/%d/episode/%d", showID, season, episode))
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(
+		ctx,
+		`insert into episodes (show_id, season_number, episode_number, tmdb_id, data, fetched_at) values($1, $2, $3, $4, $5, coalesce($6::timestamp, now()))
+    on conflict (show_id, season_number, episode_number) do update set tmdb_id = excluded.tmdb_id, data = excluded.data, fetched_at = excluded.fetched_at`,
+		showID,
+		season,
+		episode,
+		tmdbID,
+		data,
+		fetchedAt,
+	)
+	return err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"slices"
+	"tmdb_scraper/models"
+)
+
+func (r *Repo) createKeywordTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists keywords (
+    id int primary key,
+    name text not null
+    )`)
+	if err != nil {
+		log.Println("Error creating keywords table", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create table if not exists title_keywords (
+    type varchar(10) not null,
+    tmdb_id int not null,
+    keyword_id int not null references keywords (id),
+    primary key (type, tmdb_id, keyword_id)
+    )`)
+	if err != nil {
+		log.Println("Error creating title_keywords table", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create index if not exists title_keywords_keyword_idx
+    on title_keywords (keyword_id, type)`)
+	if err != nil {
+		log.Println("Error creating title_keywords index", err)
+		return err
+	}
+	return nil
+}
+
+// StoreKeywords replaces the keywords of an item, adding keywords that are
+// not known yet.
+func (r *Repo) StoreKeywords(ctx context.Context, tp string, tmdbId int, keywords []models.Keyword) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(
+		ctx,
+		`delete from title_keywords where type = $1 and tmdb_id = $2`,
+		tp,
+		tmdbId,
+	)
+	if err != nil {
+		return err
+	}
+
+	// The keyword rows are shared by every title. Jobs that store titles in
+	// parallel lock them in the same order, lowest id first, so they can't
+	// deadlock on each other.
+	keywords = slices.Clone(keywords)
+	slices.SortFunc(keywords, func(a, b models.Keyword) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	for _, k := range keywords {
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into keywords (id, name) values($1, $2)
+    on conflict (id) do update set name = excluded.name`,
+			k.ID,
+			k.Name,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into title_keywords (type, tmdb_id, keyword_id) values($1, $2, $3)
+    on conflict do nothing`,
+			tp,
+			tmdbId,
+			k.ID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return txn.Commit()
+}
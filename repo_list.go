@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ListFilter narrows down the stored movies or shows of the listing
+// endpoints.
+type ListFilter struct {
+	Type   string
+	Limit  int
+	Offset int
+	// The certification filters need CertificationCountry, lte and gte
+	// compare by the country's certification ranking.
+	CertificationCountry string
+	Certification        string
+	CertificationLte     string
+	CertificationGte     string
+	// KeywordIDs keeps the items that have all of them, or any of them
+	// with KeywordsAny.
+	KeywordIDs  []int64
+	KeywordsAny bool
+}
+
+type DetailSummary struct {
+	Type          string  `json:"type,omitempty"`
+	ID            int     `json:"id"`
+	Title         string  `json:"title"`
+	Date          string  `json:"date"`
+	Popularity    float64 `json:"popularity"`
+	Certification string  `json:"certification,omitempty"`
+}
+
+// queryBuilder collects where clauses and their positional arguments.
+type queryBuilder struct {
+	joins []string
+	where []string
+	args  []any
+}
+
+// arg adds a query argument and returns its placeholder.
+func (q *queryBuilder) arg(v any) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (r *Repo) ListDetails(ctx context.Context, f ListFilter) ([]DetailSummary, error) {
+	q := &queryBuilder{}
+	q.where = append(q.where, "d.type = "+q.arg(f.Type))
+
+	certSelect := "null"
+	if f.CertificationCountry != "" {
+		certSelect = "c.certification"
+		q.joins = append(q.joins, fmt.Sprintf(
+			"left join certifications c on c.type = d.type and c.tmdb_id = d.tmdb_id and c.iso_3166_1 = %s",
+			q.arg(f.CertificationCountry),
+		))
+		rank := func(cert string) string {
+			return fmt.Sprintf(
+				"(select ord from certification_order o where o.type = d.type and o.iso_3166_1 = c.iso_3166_1 and o.certification = %s)",
+				cert,
+			)
+		}
+		if f.Certification != "" {
+			q.where = append(q.where, "c.certification = "+q.arg(f.Certification))
+		}
+		if f.CertificationLte != "" {
+			q.where = append(q.where, rank("c.certification")+" <= "+rank(q.arg(f.CertificationLte)))
+		}
+		if f.CertificationGte != "" {
+			q.where = append(q.where, rank("c.certification")+" >= "+rank(q.arg(f.CertificationGte)))
+		}
+	}
+
+	if len(f.KeywordIDs) > 0 {
+		match := fmt.Sprintf(
+			"(select count(*) from title_keywords k where k.type = d.type and k.tmdb_id = d.tmdb_id and k.keyword_id = any(%s))",
+			q.arg(pq.Array(f.KeywordIDs)),
+		)
+		if f.KeywordsAny {
+			q.where = append(q.where, match+" > 0")
+		} else {
+			q.where = append(q.where, match+" = "+q.arg(len(f.KeywordIDs)))
+		}
+	}
+
+	query := fmt.Sprintf(`select d.tmdb_id,
+    coalesce(d.data->>'title', d.data->>'name', ''),
+    coalesce(d.data->>'release_date', d.data->>'first_air_date', ''),
+    coalesce((d.data->>'popularity')::float, 0),
+    %s
+    from details d %s
+    where %s
+    order by d.tmdb_id
+    limit %s offset %s`,
+		certSelect,
+		strings.Join(q.joins, " "),
+		strings.Join(q.where, " and "),
+		q.arg(f.Limit),
+		q.arg(f.Offset),
+	)
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []DetailSummary{}
+	for rows.Next() {
+		var (
+			s    DetailSummary
+			cert sql.NullString
+		)
+		if err := rows.Scan(&s.ID, &s.Title, &s.Date, &s.Popularity, &cert); err != nil {
+			return nil, err
+		}
+		s.Certification = cert.String
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
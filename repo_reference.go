@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"tmdb_scraper/models"
+)
+
+func (r *Repo) createReferenceTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists genres (
+    type varchar(10) not null,
+    id int not null,
+    name text not null,
+    primary key (type, id)
+    )`)
+	if err != nil {
+		log.Println("Error creating genres table", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create table if not exists countries (
+    iso_3166_1 varchar(2) primary key,
+    english_name text not null,
+    native_name text not null
+    )`)
+	if err != nil {
+		log.Println("Error creating countries table", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create table if not exists languages (
+    iso_639_1 varchar(2) primary key,
+    english_name text not null,
+    name text not null
+    )`)
+	if err != nil {
+		log.Println("Error creating languages table", err)
+		return err
+	}
+
+	// tmdb_configuration only ever holds the row with id 1.
+	_, err = r.db.ExecContext(ctx, `create table if not exists tmdb_configuration (
+    id int primary key,
+    data jsonb not null,
+    fetched_at timestamp not null default now()
+    )`)
+	if err != nil {
+		log.Println("Error creating tmdb_configuration table", err)
+		return err
+	}
+	return nil
+}
+
+// StoreGenres replaces the genres of a type.
+func (r *Repo) StoreGenres(ctx context.Context, tp string, genres []models.Genre) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(ctx, `delete from genres where type = $1`, tp)
+	if err != nil {
+		return err
+	}
+	for _, g := range genres {
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into genres (type, id, name) values($1, $2, $3)`,
+			tp,
+			g.Id,
+			g.Name,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func (r *Repo) GetGenres(ctx context.Context) (models.ZxyGenreResponse, error) {
+	res := models.ZxyGenreResponse{
+		MovieGenre: []models.Genre{},
+		ShowGenre:  []models.Genre{},
+	}
+	rows, err := r.db.QueryContext(ctx, `select type, id, name from genres order by type, name`)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			tp string
+			g  models.Genre
+		)
+		if err := rows.Scan(&tp, &g.Id, &g.Name); err != nil {
+			return res, err
+		}
+		if tp == "movie" {
+			res.MovieGenre = append(res.MovieGenre, g)
+		} else {
+			res.ShowGenre = append(res.ShowGenre, g)
+		}
+	}
+	return res, rows.Err()
+}
+
+// StoreCountries replaces the known countries.
+func (r *Repo) StoreCountries(ctx context.Context, countries []models.Country) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(ctx, `delete from countries`)
+	if err != nil {
+		return err
+	}
+	for _, c := range countries {
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into countries (iso_3166_1, english_name, native_name) values($1, $2, $3)`,
+			c.ISO3166_1,
+			c.EnglishName,
+			c.NativeName,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func (r *Repo) GetCountries(ctx context.Context) ([]models.Country, error) {
+	rows, err := r.db.QueryContext(ctx, `select iso_3166_1, english_name, native_name from countries order by iso_3166_1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.Country{}
+	for rows.Next() {
+		var c models.Country
+		if err := rows.Scan(&c.ISO3166_1, &c.EnglishName, &c.NativeName); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+// StoreLanguages replaces the known languages.
+func (r *Repo) StoreLanguages(ctx context.Context, languages []models.Language) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(ctx, `delete from languages`)
+	if err != nil {
+		return err
+	}
+	for _, l := range languages {
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into languages (iso_639_1, english_name, name) values($1, $2, $3)`,
+			l.ISO639_1,
+			l.EnglishName,
+			l.Name,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func (r *Repo) GetLanguages(ctx context.Context) ([]models.Language, error) {
+	rows, err := r.db.QueryContext(ctx, `select iso_639_1, english_name, name from languages order by iso_639_1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.Language{}
+	for rows.Next() {
+		var l models.Language
+		if err := rows.Scan(&l.ISO639_1, &l.EnglishName, &l.Name); err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
+
+func (r *Repo) StoreConfiguration(ctx context.Context, cfg models.Configuration) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(
+		ctx,
+		`insert into tmdb_configuration (id, data, fetched_at) values(1, $1, now())
+    on conflict (id) do update set data = excluded.data, fetched_at = excluded.fetched_at`,
+		data,
+	)
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// activeStatuses are the statuses of titles whose data still changes a lot.
+var activeStatuses = []string{
+	"Returning Series",
+	"In Production",
+	"Post Production",
+	"Planned",
+	"Pilot",
+	"Rumored",
+}
+
+const (
+	// activeWeight multiplies the score of titles with an activeStatus or
+	// in_production set.
+	activeWeight = 10
+	// airedBoost puts shows whose next episode has aired since they were
+	// fetched, or airs by tomorrow, ahead of everything else.
+	airedBoost = 1e6
+)
+
+type StaleItem struct {
+	Type  string  `json:"type"`
+	ID    int     `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// GetStaleItems returns up to limit items of the given types fetched
+// longer than minAge ago, the most urgent first. The score is the age in
+// days, scaled up by popularity and for titles that are still in
+// production, with shows due a new episode on top. The age counts from
+// the last failed attempt when that is later, so items that keep failing
+// don't take the whole budget every run, and items TMDB no longer has are
+// left out.
+func (r *Repo) GetStaleItems(ctx context.Context, types []string, minAge time.Duration, limit int) ([]StaleItem, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select type, tmdb_id, score from (
+    select d.type, d.tmdb_id,
+    extract(epoch from now() - coalesce(greatest(d.fetched_at, d.attempted_at), 'epoch'::timestamp)) / 86400
+    * (1 + ln(1 + greatest(coalesce((d.data->>'popularity')::float, 0), 0)))
+    * case when d.data->>'status' = any($2) or coalesce((d.data->>'in_production')::boolean, false) then $3::float else 1 end
+    + case when nullif(d.data->'next_episode_to_air'->>'air_date', '')::date <= current_date + 1
+    and (d.fetched_at is null or d.fetched_at < nullif(d.data->'next_episode_to_air'->>'air_date', '')::date)
+    then $4::float else 0 end as score
+    from details d
+    where d.type = any($1)
+    and coalesce(greatest(d.fetched_at, d.attempted_at), 'epoch'::timestamp) < now() - $5::float * interval '1 second'
+    and not exists (select 1 from not_found n where n.type = d.type and n.tmdb_id = d.tmdb_id)
+    ) s order by score desc limit $6`,
+		pq.Array(types),
+		pq.Array(activeStatuses),
+		activeWeight,
+		airedBoost,
+		minAge.Seconds(),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []StaleItem{}
+	for rows.Next() {
+		var s StaleItem
+		if err := rows.Scan(&s.Type, &s.ID, &s.Score); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+// MarkAttempted records a failed refresh of a stored item.
+func (r *Repo) MarkAttempted(ctx context.Context, tp string, id int) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`update details set attempted_at = now() where type = $1 and tmdb_id = $2`,
+		tp,
+		id,
+	)
+	return err
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+func (r *Repo) createTranslationTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `create table if not exists translations (
+    type varchar(10) not null,
+    tmdb_id int not null,
+    iso_639_1 varchar(2) not null,
+    iso_3166_1 varchar(2) not null,
+    data jsonb not null,
+    primary key (type, tmdb_id, iso_639_1, iso_3166_1)
+    )`)
+	if err != nil {
+		log.Println("Error creating translations table", err)
+		return err
+	}
+	return nil
+}
+
+// StoreTranslations replaces the stored translations of an item in the
+// given languages, see matchesLanguage. The other languages stored for it
+// are kept, every one is replaced when languages is empty.
+func (r *Repo) StoreTranslations(ctx context.Context, tp string, tmdbId int, languages []string, records []TranslationRecord) error {
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(
+		ctx,
+		`delete from translations where type = $1 and tmdb_id = $2
+    and (cardinality($3::text[]) = 0 or iso_639_1 = any($3) or iso_639_1 || '-' || iso_3166_1 = any($3))`,
+		tp,
+		tmdbId,
+		pq.Array(languages),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into translations (type, tmdb_id, iso_639_1, iso_3166_1, data) values($1, $2, $3, $4, $5)
+    on conflict (type, tmdb_id, iso_639_1, iso_3166_1) do update set data = excluded.data`,
+			tp,
+			tmdbId,
+			rec.ISO639_1,
+			rec.ISO3166_1,
+			data,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return txn.Commit()
+}
+
+func (r *Repo) GetTranslations(ctx context.Context, tp string, tmdbId int) ([]TranslationRecord, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`select data from translations where type = $1 and tmdb_id = $2 order by iso_639_1, iso_3166_1`,
+		tp,
+		tmdbId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []TranslationRecord
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var rec TranslationRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, err
+		}
+		res = append(res, rec)
+	}
+	return res, rows.Err()
+}
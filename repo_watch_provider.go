@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+func (r *Repo) createWatchProviderTables(ctx context.Context) error {
+	// A row is kept after the title leaves the provider, left_at tells when
+	// it was first missing. first_seen starts over if it comes back.
+	_, err := r.db.ExecContext(ctx, `create table if not exists watch_providers (
+    type varchar(10) not null,
+    tmdb_id int not null,
+    iso_3166_1 varchar(2) not null,
+    provider_id int not null,
+    offer varchar(10) not null,
+    provider_name text not null,
+    first_seen timestamp not null,
+    last_seen timestamp not null,
+    left_at timestamp,
+    primary key (type, tmdb_id, iso_3166_1, provider_id, offer)
+    )`)
+	if err != nil {
+		log.Println("Error creating watch_providers table", err)
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `create index if not exists watch_providers_provider_idx
+    on watch_providers (provider_id, iso_3166_1)`)
+	if err != nil {
+		log.Println("Error creating watch_providers index", err)
+		return err
+	}
+	return nil
+}
+
+// StoreWatchProviders records the offers seen for an item at seen. Offers
+// that were available before but are missing now are marked as left.
+func (r *Repo) StoreWatchProviders(ctx context.Context, tp string, tmdbId int, offers []WatchOffer, seen time.Time) error {
+	if r.replay {
+		return nil
+	}
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	for _, o := range offers {
+		_, err = txn.ExecContext(
+			ctx,
+			`insert into watch_providers (type, tmdb_id, iso_3166_1, provider_id, offer, provider_name, first_seen, last_seen)
+    values($1, $2, $3, $4, $5, $6, $7, $7)
+    on conflict (type, tmdb_id, iso_3166_1, provider_id, offer) do update set
+    provider_name = excluded.provider_name,
+    first_seen = case when watch_providers.left_at is null then watch_providers.first_seen else excluded.first_seen end,
+    last_seen = excluded.last_seen,
+    left_at = null`,
+			tp,
+			tmdbId,
+			o.Region,
+			o.ProviderID,
+			o.Offer,
+			o.ProviderName,
+			seen,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Everything still available that was not seen just now has left.
+	_, err = txn.ExecContext(
+		ctx,
+		`update watch_providers set left_at = $3
+    where type = $1 and tmdb_id = $2 and left_at is null and last_seen < $3`,
+		tp,
+		tmdbId,
+		seen,
+	)
+	if err != nil {
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// ProviderFilter selects the titles of a provider in a country.
+type ProviderFilter struct {
+	ProviderID int
+	Region     string
+	// Type and Offer are optional.
+	Type  string
+	Offer string
+	// Status is available, left or all.
+	Status string
+	Limit  int
+	Offset int
+}
+
+type ProviderTitle struct {
+	Type         string     `json:"type"`
+	ID           int        `json:"id"`
+	Title        string     `json:"title"`
+	Offer        string     `json:"offer"`
+	ProviderName string     `json:"provider_name"`
+	FirstSeen    time.Time  `json:"first_seen"`
+	LastSeen     time.Time  `json:"last_seen"`
+	LeftAt       *time.Time `json:"left_at,omitempty"`
+}
+
+func (r *Repo) ListProviderTitles(ctx context.Context, f ProviderFilter) ([]ProviderTitle, error) {
+	q := &queryBuilder{}
+	q.where = append(q.where,
+		"w.provider_id = "+q.arg(f.ProviderID),
+		"w.iso_3166_1 = "+q.arg(f.Region),
+	)
+	if f.Type != "" {
+		q.where = append(q.where, "w.type = "+q.arg(f.Type))
+	}
+	if f.Offer != "" {
+		q.where = append(q.where, "w.offer = "+q.arg(f.Offer))
+	}
+	switch f.Status {
+	case "available":
+		q.where = append(q.where, "w.left_at is null")
+	case "left":
+		q.where = append(q.where, "w.left_at is not null")
+	}
+
+	query := fmt.Sprintf(`select w.type, w.tmdb_id,
+    coalesce(d.data->>'title', d.data->>'name', ''),
+    w.offer, w.provider_name, w.first_seen, w.last_seen, w.left_at
+    from watch_providers w
+    left join details d on d.type = w.type and d.tmdb_id = w.tmdb_id
+    where %s
+    order by w.first_seen desc, w.type, w.tmdb_id, w.offer
+    limit %s offset %s`,
+		strings.Join(q.where, " and "),
+		q.arg(f.Limit),
+		q.arg(f.Offset),
+	)
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []ProviderTitle{}
+	for rows.Next() {
+		var (
+			t      ProviderTitle
+			leftAt sql.NullTime
+		)
+		err := rows.Scan(&t.Type, &t.ID, &t.Title, &t.Offer, &t.ProviderName, &t.FirstSeen, &t.LastSeen, &leftAt)
+		if err != nil {
+			return nil, err
+		}
+		if leftAt.Valid {
+			t.LeftAt = &leftAt.Time
+		}
+		res = append(res, t)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Reprocessor rebuilds the stored items from the archived raw responses,
+// so model changes can be applied without asking TMDB again.
+type Reprocessor struct {
+	repo     *Repo
+	fetchers map[string]func(ctx context.Context, v int, opts FetchOptions) error
+}
+
+// NewReprocessor builds crawlers on a replaying Usecase and Repo. Like
+// NewApp it takes their settings from cfg.
+func NewReprocessor(usecase *Usecase, repo *Repo, cfg Config) *Reprocessor {
+	uc := usecase.Replay(repo)
+	repo = repo.Replay()
+	at := cfg.TMDB.AccessToken
+	validator := NewValidator(repo, cfg.Validation.Rules, cfg.Validation.Reject)
+	mc := NewMovieCrawler(uc, at, repo, validator, cfg.Crawl.MovieMaxID, cfg.Crawl.Languages, time.Duration(cfg.Crawl.CollectionTTL))
+	sc := NewShowCrawler(uc, at, repo, validator, cfg.Crawl.ShowMaxID, cfg.Crawl.Languages)
+	pc := NewPersonCrawler(uc, at, repo, validator, cfg.Crawl.PersonMaxID)
+	return &Reprocessor{
+		repo: repo,
+		fetchers: map[string]func(ctx context.Context, v int, opts FetchOptions) error{
+			"movie":  mc.Fetch,
+			"show":   sc.Fetch,
+			"person": pc.Fetch,
+		},
+	}
+}
+
+// Run reprocesses every archived item of tp, or only id when it is not 0.
+// It returns how many items were rebuilt.
+func (p *Reprocessor) Run(ctx context.Context, tp string, id int, opts FetchOptions) (int, error) {
+	fetch, ok := p.fetchers[tp]
+	if !ok {
+		return 0, fmt.Errorf("Invalid type %s", tp)
+	}
+	if id != 0 {
+		if err := fetch(ctx, id, opts); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	tmdbType := tp
+	if tp == "show" {
+		tmdbType = "tv"
+	}
+	done := 0
+	after := 0
+	for {
+		ids, err := p.repo.GetArchivedIDs(ctx, tmdbType, after, crawlPageSize)
+		if err != nil {
+			return done, err
+		}
+		if len(ids) == 0 {
+			return done, nil
+		}
+		for _, v := range ids {
+			if ctx.Err() != nil {
+				return done, nil
+			}
+			// The replaying Repo doesn't record failures.
+			if err := fetch(ctx, v, opts); err != nil {
+				fmt.Println("Error reprocessing", tp, v, err)
+				continue
+			}
+			done++
+		}
+		after = ids[len(ids)-1]
+		fmt.Printf("Reprocessed %d %ss up to id %d\n", done, tp, after)
+	}
+}
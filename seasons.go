@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"tmdb_scraper/models"
+)
+
+const (
+	// MaxAppendToResponse is how many sub requests TMDB accepts in one
+	// append_to_response.
+	MaxAppendToResponse = 20
+	// seasonConcurrency is how many season batches of a show are in flight
+	// at once. They still queue behind the HttpClient rate limit.
+	seasonConcurrency = 4
+)
+
+// MissingSeasonsError reports the seasons of a show that TMDB listed but
+// did not return any data for.
+type MissingSeasonsError struct {
+	ShowID  string
+	Seasons []int64
+}
+
+func (e *MissingSeasonsError) Error() string {
+	numbers := make([]string, len(e.Seasons))
+	for i, s := range e.Seasons {
+		numbers[i] = fmt.Sprintf("%d", s)
+	}
+	return fmt.Sprintf("show %s is missing seasons %s", e.ShowID, strings.Join(numbers, ","))
+}
+
+// seasonBatches splits the numbers of the listed seasons into groups of at
+// most size, leaving out the specials (season 0) unless specials is set.
+func seasonBatches(listed []models.Season, specials bool, size int) [][]int64 {
+	var batches [][]int64
+	var batch []int64
+	for _, s := range listed {
+		if s.SeasonNumber == 0 && !specials {
+			continue
+		}
+		batch = append(batch, s.SeasonNumber)
+		if len(batch) == size {
+			batches = append(batches, batch)
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+func seasonKey(number int64) string {
+	return fmt.Sprintf("season/%d", number)
+}
+
+// seasonBatchURL is the show url appending every season of the batch.
+func seasonBatchURL(base string, id string, batch []int64) string {
+	keys := make([]string, len(batch))
+	for i, number := range batch {
+		keys[i] = seasonKey(number)
+	}
+	return fmt.Sprintf("%s/tv/%s?append_to_response=%s", base, id, strings.Join(keys, ","))
+}
+
+// getSeasons fetches the full data, with the episodes, of the listed
+// seasons in batches of MaxAppendToResponse, several batches at once. The
+// seasons are returned in the listed order. Seasons TMDB sent nothing for
+// are left out and reported in a *MissingSeasonsError next to the rest.
+func (u *Usecase) getSeasons(ctx context.Context, id string, listed []models.Season, at string) ([]models.Season, error) {
+	batches := seasonBatches(listed, u.specials, MaxAppendToResponse)
+	results := make([][]models.Season, len(batches))
+	missing := make([][]int64, len(batches))
+	errs := make([]error, len(batches))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, seasonConcurrency)
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i], missing[i], errs[i] = u.getSeasonBatch(ctx, id, batch, at)
+			if errs[i] != nil {
+				// The show fails as a whole, don't bother with the rest.
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := firstSeasonError(errs); err != nil {
+		return nil, err
+	}
+
+	var seasons []models.Season
+	var missed []int64
+	for i := range batches {
+		seasons = append(seasons, results[i]...)
+		missed = append(missed, missing[i]...)
+	}
+	if len(missed) > 0 {
+		return seasons, &MissingSeasonsError{ShowID: id, Seasons: missed}
+	}
+	return seasons, nil
+}
+
+// firstSeasonError picks the error that failed the show. A failed batch
+// cancels its siblings, so their context errors are only returned when
+// nothing else went wrong.
+func firstSeasonError(errs []error) error {
+	var canceled error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, context.Canceled) {
+			if canceled == nil {
+				canceled = err
+			}
+			continue
+		}
+		return err
+	}
+	return canceled
+}
+
+func (u *Usecase) getSeasonBatch(ctx context.Context, id string, batch []int64, at string) ([]models.Season, []int64, error) {
+	url := seasonBatchURL(u.tmdbApiBaseUrl, id, batch)
+	body, err := u.doGet(ctx, url, at)
+	if err != nil {
+		fmt.Println("Error sending get series request to TMDB", err)
+		return nil, nil, err
+	}
+
+	rawMap := make(map[string]json.RawMessage, 0)
+	err = json.Unmarshal(body, &rawMap)
+	if err != nil {
+		fmt.Println("Error unmarshalling season response", err)
+		return nil, nil, decodeError(u.endpoint(url), err)
+	}
+
+	var seasons []models.Season
+	var missing []int64
+	for _, number := range batch {
+		data, ok := rawMap[seasonKey(number)]
+		if !ok || string(data) == "null" {
+			missing = append(missing, number)
+			continue
+		}
+		var season models.Season
+		u.checkDrift(ctx, "season", data, season)
+		err = json.Unmarshal(data, &season)
+		if err != nil {
+			fmt.Println("Error unmarshalling show season response", err, string(data))
+			return nil, nil, decodeError(u.endpoint(url), err)
+		}
+		seasons = append(seasons, season)
+	}
+	return seasons, missing, nil
+}
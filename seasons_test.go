@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+	"tmdb_scraper/models"
+)
+
+func listedSeasons(numbers ...int64) []models.Season {
+	res := make([]models.Season, len(numbers))
+	for i, n := range numbers {
+		res[i].SeasonNumber = n
+	}
+	return res
+}
+
+func TestSeasonBatches(t *testing.T) {
+	var many []int64
+	for i := int64(1); i <= 41; i++ {
+		many = append(many, i)
+	}
+
+	tests := []struct {
+		name     string
+		listed   []models.Season
+		specials bool
+		want     [][]int64
+	}{
+		{"no seasons", nil, false, nil},
+		{"specials skipped", listedSeasons(0, 1, 2), false, [][]int64{{1, 2}}},
+		{"specials included", listedSeasons(0, 1, 2), true, [][]int64{{0, 1, 2}}},
+		{"only specials skipped", listedSeasons(0), false, nil},
+		{"specials last", listedSeasons(1, 2, 0), false, [][]int64{{1, 2}}},
+		{"append limit", listedSeasons(many...), false, [][]int64{many[:20], many[20:40], many[40:]}},
+		{"append limit with specials", listedSeasons(append([]int64{0}, many[:20]...)...), true, [][]int64{append([]int64{0}, many[:19]...), many[19:20]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := seasonBatches(tt.listed, tt.specials, MaxAppendToResponse)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("seasonBatches() = %v, want %v", got, tt.want)
+			}
+			for _, batch := range got {
+				if len(batch) > MaxAppendToResponse {
+					t.Errorf("batch of %d seasons exceeds the append limit", len(batch))
+				}
+			}
+		})
+	}
+}
+
+func TestSeasonBatchURL(t *testing.T) {
+	got := seasonBatchURL("https://api", "1399", []int64{0, 1, 2})
+	want := "https://api/tv/1399?append_to_response=season/0,season/1,season/2"
+	if got != want {
+		t.Errorf("seasonBatchURL() = %q, want %q", got, want)
+	}
+}
+
+func TestGetSeasonBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"id": 1399,
+			"season/1": {"_id": "a", "season_number": 1, "episodes": [{"episode_number": 1}]},
+			"season/2": null,
+			"season/4": {"_id": "d", "season_number": 4, "episodes": []}
+		}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient(0, time.Second)
+	defer client.Close()
+	u := NewUsecase(srv.URL, client, time.Second, nil, nil, false, nil)
+
+	seasons, missing, err := u.getSeasonBatch(context.Background(), "1399", []int64{1, 2, 3, 4}, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int64
+	for _, s := range seasons {
+		got = append(got, s.SeasonNumber)
+	}
+	if !reflect.DeepEqual(got, []int64{1, 4}) {
+		t.Errorf("seasons = %v, want [1 4]", got)
+	}
+	if !reflect.DeepEqual(missing, []int64{2, 3}) {
+		t.Errorf("missing = %v, want [2 3]", missing)
+	}
+	if len(seasons[0].Episodes) != 1 {
+		t.Errorf("season 1 has %d episodes, want 1", len(seasons[0].Episodes))
+	}
+}
+
+func TestGetSeasonsMissing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"season/1": {"season_number": 1}}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient(0, time.Second)
+	defer client.Close()
+	u := NewUsecase(srv.URL, client, time.Second, nil, nil, false, nil)
+
+	seasons, err := u.getSeasons(context.Background(), "1", listedSeasons(0, 1, 2), "token")
+	var missing *MissingSeasonsError
+	if !errors.As(err, &missing) {
+		t.Fatalf("err = %v, want a *MissingSeasonsError", err)
+	}
+	if !reflect.DeepEqual(missing.Seasons, []int64{2}) {
+		t.Errorf("missing seasons = %v, want [2]", missing.Seasons)
+	}
+	if len(seasons) != 1 {
+		t.Errorf("got %d seasons, want 1", len(seasons))
+	}
+}
+
+func TestFirstSeasonError(t *testing.T) {
+	canceled := &APIError{Kind: ErrNetwork, Err: context.Canceled}
+	upstream := &APIError{Kind: ErrUpstream5xx, Status: 502}
+
+	tests := []struct {
+		name string
+		errs []error
+		want error
+	}{
+		{"none", []error{nil, nil}, nil},
+		{"canceled sibling first", []error{canceled, upstream, canceled}, upstream},
+		{"only canceled", []error{nil, canceled}, canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := firstSeasonError(tt.errs); got != tt.want {
+				t.Errorf("firstSeasonError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
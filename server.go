@@ -0,0 +1,904 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func (a *App) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// GET /jobs lists the jobs with whether they are paused by the circuit
+	// breaker.
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, a.manager.GetJobs(), nil)
+	})
+
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		stats, err := a.manager.GetStats(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		body, _ := json.Marshal(stats)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	})
+
+	mux.HandleFunc("POST /start", func(w http.ResponseWriter, r *http.Request) {
+		type Input struct {
+			Tp        string `json:"type"`
+			Start     int    `json:"start"`
+			End       int    `json:"end"`
+			Overwrite bool   `json:"overwrite"`
+			// Referenced limits a person crawl to people found in the
+			// credits of stored movies and shows.
+			Referenced bool `json:"referenced"`
+			// Discover is the filter of a discover job.
+			Discover DiscoverSpec `json:"discover"`
+			// Types and Budget narrow a stale refresh job.
+			Types  []string `json:"types"`
+			Budget int      `json:"budget"`
+			FetchOptions
+		}
+		var input Input
+		bodyBytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			fmt.Println("Error reading body", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer r.Body.Close()
+
+		err = json.Unmarshal(bodyBytes, &input)
+		if err != nil {
+			fmt.Println("Error unmarshalling body", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if input.Tp != "movie" && input.Tp != "show" && input.Tp != "person" && input.Tp != "company" && input.Tp != "network" &&
+			input.Tp != "imdb" && input.Tp != "reference" && input.Tp != "charts" && input.Tp != "discover" && input.Tp != "stale" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid type"))
+			return
+		}
+
+		switch input.Tp {
+		case "movie":
+			err = a.manager.StartMovieSync(input.Start, input.End, input.Overwrite, input.FetchOptions)
+		case "person":
+			err = a.manager.StartPersonSync(input.Start, input.End, input.Overwrite, input.Referenced, input.FetchOptions)
+		case "show":
+			err = a.manager.StartShowSync(input.Start, input.End, input.Overwrite, input.FetchOptions)
+		case "company", "network":
+			err = a.manager.StartCompanySync(input.Tp, input.Overwrite)
+		case "imdb":
+			err = a.manager.StartIMDBSync()
+		case "reference":
+			err = a.manager.StartReferenceSync()
+		case "charts":
+			err = a.manager.StartChartSync()
+		case "stale":
+			err = a.manager.StartStaleRefresh(input.Types, input.Budget)
+		case "discover":
+			err = a.manager.StartDiscoverSync(input.Discover, input.Overwrite, input.FetchOptions)
+		}
+		if err != nil {
+			w.WriteHeader(startErrorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Process started successfully"))
+	})
+
+	mux.HandleFunc("POST /stop", func(w http.ResponseWriter, r *http.Request) {
+		type Input struct {
+			Tp        string `json:"type"`
+			Start     int    `json:"start"`
+			End       int    `json:"end"`
+			Overwrite bool   `json:"overwrite"`
+		}
+		var input Input
+		bodyBytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			fmt.Println("Error reading body", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer r.Body.Close()
+
+		err = json.Unmarshal(bodyBytes, &input)
+		if err != nil {
+			fmt.Println("Error unmarshalling body", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if input.Tp != "movie" && input.Tp != "show" && input.Tp != "person" && input.Tp != "company" && input.Tp != "network" &&
+			input.Tp != "imdb" && input.Tp != "reference" && input.Tp != "charts" && input.Tp != "discover" && input.Tp != "stale" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid type"))
+			return
+		}
+
+		if input.Tp == "movie" {
+			a.manager.StopMovieScrape()
+		}
+
+		if input.Tp == "person" {
+			a.manager.StopPersonScrape()
+		}
+
+		if input.Tp == "show" {
+			a.manager.StopShowScrape()
+		}
+
+		if input.Tp == "company" || input.Tp == "network" {
+			a.manager.StopCompanyScrape(input.Tp)
+		}
+
+		if input.Tp == "imdb" {
+			a.manager.StopImdbScrape()
+		}
+
+		if input.Tp == "reference" {
+			a.manager.StopReferenceSync()
+		}
+
+		if input.Tp == "charts" {
+			a.manager.StopChartSync()
+		}
+
+		if input.Tp == "discover" {
+			a.manager.StopDiscoverSync()
+		}
+
+		if input.Tp == "stale" {
+			a.manager.StopStaleRefresh()
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Process stopped successfully"))
+	})
+
+	mux.HandleFunc("GET /collections/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid id"))
+			return
+		}
+
+		data, err := a.repo.GetCollection(r.Context(), id)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	})
+
+	mux.HandleFunc("GET /genres", func(w http.ResponseWriter, r *http.Request) {
+		genres, err := a.repo.GetGenres(r.Context())
+		writeJSON(w, genres, err)
+	})
+
+	mux.HandleFunc("GET /countries", func(w http.ResponseWriter, r *http.Request) {
+		countries, err := a.repo.GetCountries(r.Context())
+		writeJSON(w, countries, err)
+	})
+
+	mux.HandleFunc("GET /languages", func(w http.ResponseWriter, r *http.Request) {
+		languages, err := a.repo.GetLanguages(r.Context())
+		writeJSON(w, languages, err)
+	})
+
+	for tp, path := range map[string]string{"company": "companies", "network": "networks"} {
+		mux.HandleFunc(fmt.Sprintf("GET /%s/{id}", path), func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.Atoi(r.PathValue("id"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Invalid id"))
+				return
+			}
+
+			data, err := a.repo.GetOrg(r.Context(), tp, id)
+			if err == sql.ErrNoRows {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("not found"))
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(data)
+		})
+
+		mux.HandleFunc(fmt.Sprintf("GET /%s/{id}/titles", path), func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.Atoi(r.PathValue("id"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Invalid id"))
+				return
+			}
+			limit, offset, err := parseLimitOffset(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			items, err := a.repo.ListOrgTitles(r.Context(), tp, id, limit, offset)
+			writeJSON(w, map[string]any{
+				"results": items,
+				"limit":   limit,
+				"offset":  offset,
+			}, err)
+		})
+	}
+
+	// GET /charts?chart=movie/popular&date=2024-05-01 returns the latest
+	// snapshot of a chart taken on or before date, today by default.
+	mux.HandleFunc("GET /charts", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		chart := q.Get("chart")
+		if chart == "" {
+			writeJSON(w, Charts, nil)
+			return
+		}
+		if !validChart(chart) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid chart"))
+			return
+		}
+		date, err := parseDate(q.Get("date"), time.Now().UTC())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		snapshot, rows, err := a.repo.GetChart(r.Context(), chart, date)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+			return
+		}
+		writeJSON(w, map[string]any{
+			"chart":   chart,
+			"date":    snapshot.Format(time.DateOnly),
+			"results": rows,
+		}, err)
+	})
+
+	// GET /charts/positions?type=movie&id=550 returns the ranks of an item
+	// over time, narrowed by ?chart=, ?from= and ?to=.
+	mux.HandleFunc("GET /charts/positions", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		tp := q.Get("type")
+		if tp != "movie" && tp != "show" && tp != "person" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid type"))
+			return
+		}
+		id, err := strconv.Atoi(q.Get("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid id"))
+			return
+		}
+		chart := q.Get("chart")
+		if chart != "" && !validChart(chart) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid chart"))
+			return
+		}
+		to, err := parseDate(q.Get("to"), time.Now().UTC())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		from, err := parseDate(q.Get("from"), to.AddDate(0, 0, -30))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		positions, err := a.repo.GetChartPositions(r.Context(), tp, id, chart, from, to)
+		writeJSON(w, positions, err)
+	})
+
+	// GET /stale?type=show&limit=20 previews what the next stale refresh
+	// run would pick.
+	mux.HandleFunc("GET /stale", func(w http.ResponseWriter, r *http.Request) {
+		var types []string
+		if tp := r.URL.Query().Get("type"); tp != "" {
+			types = []string{tp}
+		} else {
+			types = []string{"movie", "show", "person"}
+		}
+		limit, _, err := parseLimitOffset(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		items, err := a.repo.GetStaleItems(r.Context(), types, time.Duration(a.cfg.Refresh.MinAge), limit)
+		writeJSON(w, items, err)
+	})
+
+	mux.HandleFunc("GET /calendar", func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseCalendarFilter(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		entries, err := a.repo.GetCalendar(r.Context(), filter)
+		writeJSON(w, entries, err)
+	})
+
+	mux.HandleFunc("GET /calendar.ics", func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseCalendarFilter(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		entries, err := a.repo.GetCalendar(r.Context(), filter)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if err := writeICal(w, entries, time.Now()); err != nil {
+			fmt.Println("Error writing calendar", err)
+		}
+	})
+
+	// GET /failed?type=show counts the failed items by the kind of their
+	// latest failure, retry them with retry-failed -kind.
+	mux.HandleFunc("GET /failed", func(w http.ResponseWriter, r *http.Request) {
+		counts, err := a.repo.GetFailedCounts(r.Context(), r.URL.Query().Get("type"))
+		writeJSON(w, counts, err)
+	})
+
+	// GET /data-quality?type=show&rule=season_count lists the items that
+	// broke a validation rule when they were last fetched.
+	mux.HandleFunc("GET /data-quality", func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := parseLimitOffset(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		q := r.URL.Query()
+		issues, err := a.repo.GetViolations(r.Context(), q.Get("type"), q.Get("rule"), limit, offset)
+		writeJSON(w, issues, err)
+	})
+
+	// GET /drift?endpoint=movie lists where TMDB payloads stopped matching
+	// the models, filled when tmdb.detect_drift is on.
+	mux.HandleFunc("GET /drift", func(w http.ResponseWriter, r *http.Request) {
+		report, err := a.repo.GetDrift(r.Context(), r.URL.Query().Get("endpoint"))
+		writeJSON(w, report, err)
+	})
+
+	mux.HandleFunc("GET /providers/{id}/titles", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid id"))
+			return
+		}
+		filter, err := parseProviderFilter(r, id)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		items, err := a.repo.ListProviderTitles(r.Context(), filter)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		body, _ := json.Marshal(map[string]any{
+			"results": items,
+			"limit":   filter.Limit,
+			"offset":  filter.Offset,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	})
+
+	for _, tp := range []string{"movie", "show"} {
+		mux.HandleFunc(fmt.Sprintf("GET /%ss", tp), func(w http.ResponseWriter, r *http.Request) {
+			filter, err := parseListFilter(r, tp)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			items, err := a.repo.ListDetails(r.Context(), filter)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			body, _ := json.Marshal(map[string]any{
+				"results": items,
+				"limit":   filter.Limit,
+				"offset":  filter.Offset,
+			})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(body)
+		})
+
+		mux.HandleFunc(fmt.Sprintf("GET /%ss/{id}", tp), func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.Atoi(r.PathValue("id"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Invalid id"))
+				return
+			}
+
+			data, err := a.repo.GetDetails(r.Context(), tp, id)
+			if err == sql.ErrNoRows {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("not found"))
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if languages := requestedLanguages(r); len(languages) > 0 {
+				records, err := a.repo.GetTranslations(r.Context(), tp, id)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte(err.Error()))
+					return
+				}
+				if rec, ok := bestTranslation(records, languages); ok {
+					data, err = localize(data, tp, rec)
+					if err != nil {
+						w.WriteHeader(http.StatusInternalServerError)
+						w.Write([]byte(err.Error()))
+						return
+					}
+					w.Header().Set("Content-Language", rec.Tag())
+				}
+			}
+			w.Header().Set("Vary", "Accept-Language")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(data)
+		})
+
+		mux.HandleFunc(fmt.Sprintf("GET /%ss/{id}/translations", tp), func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.Atoi(r.PathValue("id"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Invalid id"))
+				return
+			}
+
+			records, err := a.repo.GetTranslations(r.Context(), tp, id)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			languages := requestedLanguages(r)
+			res := []TranslationRecord{}
+			for _, rec := range records {
+				if matchesLanguage(languages, rec.ISO639_1, rec.ISO3166_1) {
+					res = append(res, rec)
+				}
+			}
+			body, _ := json.Marshal(res)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(body)
+		})
+
+		mux.HandleFunc(fmt.Sprintf("POST /%ss/{id}/refresh", tp), func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.Atoi(r.PathValue("id"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Invalid id"))
+				return
+			}
+			opts, err := fetchOptionsFromQuery(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			data, err := a.manager.Refresh(r.Context(), tp, id, opts)
+			if err != nil {
+				w.WriteHeader(refreshErrorStatus(err))
+				w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(data)
+		})
+
+		mux.HandleFunc(fmt.Sprintf("POST /%ss/refresh", tp), func(w http.ResponseWriter, r *http.Request) {
+			type Input struct {
+				IDs []int `json:"ids"`
+			}
+			type Result struct {
+				ID    int             `json:"id"`
+				Data  json.RawMessage `json:"data,omitempty"`
+				Error string          `json:"error,omitempty"`
+			}
+			var input Input
+			bodyBytes, err := io.ReadAll(r.Body)
+			if err != nil {
+				fmt.Println("Error reading body", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			defer r.Body.Close()
+
+			err = json.Unmarshal(bodyBytes, &input)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Invalid body"))
+				return
+			}
+			if len(input.IDs) == 0 || len(input.IDs) > MaxRefreshBatch {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(fmt.Sprintf("ids must contain between 1 and %d entries", MaxRefreshBatch)))
+				return
+			}
+			opts, err := fetchOptionsFromQuery(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			results := make([]Result, 0, len(input.IDs))
+			for _, id := range input.IDs {
+				data, err := a.manager.Refresh(r.Context(), tp, id, opts)
+				if r.Context().Err() != nil {
+					return
+				}
+				res := Result{ID: id, Data: data}
+				if err != nil {
+					res.Error = err.Error()
+				}
+				results = append(results, res)
+			}
+			body, _ := json.Marshal(results)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(body)
+		})
+	}
+
+	return mux
+}
+
+// MaxRefreshBatch caps the ids accepted by the batch refresh endpoints, the
+// request is answered only once every item was fetched.
+const MaxRefreshBatch = 100
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// parseListFilter reads the paging and filter query parameters of the
+// listing endpoints.
+func parseListFilter(r *http.Request, tp string) (ListFilter, error) {
+	q := r.URL.Query()
+	f := ListFilter{
+		Type:                 tp,
+		CertificationCountry: strings.ToUpper(q.Get("certification_country")),
+		Certification:        q.Get("certification"),
+		CertificationLte:     q.Get("certification_lte"),
+		CertificationGte:     q.Get("certification_gte"),
+	}
+
+	var err error
+	f.Limit, f.Offset, err = parseLimitOffset(r)
+	if err != nil {
+		return f, err
+	}
+
+	// Like TMDB's with_keywords, "1,2" wants both keywords and "1|2" either.
+	if v := q.Get("keywords"); v != "" {
+		sep := ","
+		if strings.Contains(v, "|") {
+			sep = "|"
+			f.KeywordsAny = true
+		}
+		for _, s := range strings.Split(v, sep) {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return f, fmt.Errorf("Invalid keyword id %s", s)
+			}
+			f.KeywordIDs = append(f.KeywordIDs, id)
+		}
+	}
+
+	if f.CertificationCountry == "" && (f.Certification != "" || f.CertificationLte != "" || f.CertificationGte != "") {
+		return f, fmt.Errorf("certification filters need certification_country")
+	}
+	return f, nil
+}
+
+// writeJSON responds with v, or with a 500 when loading it failed.
+func writeJSON(w http.ResponseWriter, v any, err error) {
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// MaxCalendarDays bounds the range of a calendar request.
+const MaxCalendarDays = 366
+
+// parseCalendarFilter reads ?from= and ?to= (today and 30 days later by
+// default) and the optional ?shows=1399,94997 and ?networks=213,49 lists.
+func parseCalendarFilter(r *http.Request) (CalendarFilter, error) {
+	q := r.URL.Query()
+	var (
+		f   CalendarFilter
+		err error
+	)
+	f.From, err = parseDate(q.Get("from"), time.Now().UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return f, err
+	}
+	f.To, err = parseDate(q.Get("to"), f.From.AddDate(0, 0, 30))
+	if err != nil {
+		return f, err
+	}
+	if f.To.Before(f.From) {
+		return f, fmt.Errorf("to must not be before from")
+	}
+	if f.To.Sub(f.From) > MaxCalendarDays*24*time.Hour {
+		return f, fmt.Errorf("the range must not be longer than %d days", MaxCalendarDays)
+	}
+	f.ShowIDs, err = parseIDList(q.Get("shows"))
+	if err != nil {
+		return f, err
+	}
+	f.NetworkIDs, err = parseIDList(q.Get("networks"))
+	return f, err
+}
+
+// parseIDList reads a comma separated list of ids.
+func parseIDList(v string) ([]int64, error) {
+	if v == "" {
+		return nil, nil
+	}
+	var res []int64
+	for _, s := range strings.Split(v, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid id %s", s)
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+// parseDate reads a YYYY-MM-DD query value, def when it is empty.
+func parseDate(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return t, fmt.Errorf("Invalid date %s, want YYYY-MM-DD", v)
+	}
+	return t, nil
+}
+
+// parseLimitOffset reads the paging query parameters shared by the
+// listing endpoints.
+func parseLimitOffset(r *http.Request) (int, int, error) {
+	q := r.URL.Query()
+	limit, offset := DefaultListLimit, 0
+	if v := q.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 || l > MaxListLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", MaxListLimit)
+		}
+		limit = l
+	}
+	if v := q.Get("offset"); v != "" {
+		o, err := strconv.Atoi(v)
+		if err != nil || o < 0 {
+			return 0, 0, fmt.Errorf("offset must not be negative")
+		}
+		offset = o
+	}
+	return limit, offset, nil
+}
+
+// parseProviderFilter reads ?region=US (required), ?type=movie|show,
+// ?offer=flatrate|rent|buy|ads|free and ?status=available|left|all.
+func parseProviderFilter(r *http.Request, id int) (ProviderFilter, error) {
+	q := r.URL.Query()
+	f := ProviderFilter{
+		ProviderID: id,
+		Region:     strings.ToUpper(q.Get("region")),
+		Type:       q.Get("type"),
+		Offer:      q.Get("offer"),
+		Status:     q.Get("status"),
+	}
+	if len(f.Region) != 2 {
+		return f, fmt.Errorf("region must be a country code like US")
+	}
+	if f.Type != "" && f.Type != "movie" && f.Type != "show" {
+		return f, fmt.Errorf("Invalid type %s", f.Type)
+	}
+	if f.Offer != "" && !validWatchOffer(f.Offer) {
+		return f, fmt.Errorf("Invalid offer %s", f.Offer)
+	}
+	if f.Status == "" {
+		f.Status = "available"
+	}
+	if f.Status != "available" && f.Status != "left" && f.Status != "all" {
+		return f, fmt.Errorf("Invalid status %s", f.Status)
+	}
+
+	var err error
+	f.Limit, f.Offset, err = parseLimitOffset(r)
+	return f, err
+}
+
+// fetchOptionsFromQuery reads FetchOptions from the query string of the
+// refresh endpoints, e.g. ?deep=true&languages=de,fr-FR.
+func fetchOptionsFromQuery(r *http.Request) (FetchOptions, error) {
+	q := r.URL.Query()
+	deep, _ := strconv.ParseBool(q.Get("deep"))
+	opts := FetchOptions{
+		Deep: deep,
+	}
+	if languages := q.Get("languages"); languages != "" {
+		var err error
+		if opts.Languages, err = parseLanguages(languages); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// startErrorStatus is the status of a job /start could not start.
+func startErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrJobRunning):
+		return http.StatusConflict
+	case errors.Is(err, ErrShuttingDown):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+func refreshErrorStatus(err error) int {
+	if isNotFound(err) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
+
+// Serve runs the HTTP API until SIGINT/SIGTERM and then shuts everything
+// down in order.
+func (a *App) Serve() error {
+	srv := &http.Server{
+		Addr:    a.cfg.ListenAddr,
+		Handler: a.routes(),
+	}
+	// Reference data is small and needed to make sense of everything else,
+	// so it is kept fresh without waiting for a /start call.
+	if err := a.manager.StartReferenceSync(); err != nil {
+		log.Println("Error starting reference sync", err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		fmt.Println("Starting http server")
+		err := srv.ListenAndServe()
+		if err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	// A server that can't listen, say on a port in use, shuts the jobs
+	// down just the same and is reported.
+	var err error
+	select {
+	case <-sig:
+	case err = <-serveErr:
+		log.Println("Error running http server", err)
+	}
+
+	log.Println("Shutting down...")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Duration(a.cfg.ShutdownTimeout))
+	defer shutdownCancel()
+
+	// The API calls and the jobs drain at the same time, so a slow handler
+	// can't use up the deadline of the jobs. The manager refuses new jobs
+	// from then on.
+	var wg sync.WaitGroup
+	var jobsErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		jobsErr = a.manager.ShutDown(shutdownCtx)
+	}()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Error shutting down http server", err)
+	}
+	wg.Wait()
+	if jobsErr != nil {
+		// Jobs may still be writing, closing the db and the client under
+		// them would fail their writes halfway. Exiting ends them instead.
+		log.Println("Jobs did not finish before the shutdown deadline, exiting without closing the db", jobsErr)
+		return errors.Join(err, fmt.Errorf("jobs still running at the shutdown deadline: %w", jobsErr))
+	}
+	a.Close()
+	log.Println("Shutdown complete")
+	return err
+}
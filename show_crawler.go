@@ -3,105 +3,176 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
+	"tmdb_scraper/models"
 )
 
 type ShowCrwaler struct {
-	usecase *Usecase
-	at      string
-	repo    *Repo
+	usecase   *Usecase
+	at        string
+	repo      *Repo
+	validator *Validator
+	maxID     int
+	// languages are the translations stored when a job doesn't ask for
+	// specific ones.
+	languages []string
 }
 
-func NewShowCrawler(usecase *Usecase, at string, repo *Repo) *ShowCrwaler {
+func NewShowCrawler(usecase *Usecase, at string, repo *Repo, validator *Validator, maxID int, languages []string) *ShowCrwaler {
 	return &ShowCrwaler{
-		usecase: usecase,
-		at:      at,
-		repo:    repo,
+		usecase:   usecase,
+		validator: validator,
+		at:        at,
+		repo:      repo,
+		maxID:     maxID,
+		languages: languages,
 	}
 }
 
-func (m *ShowCrwaler) Start(ctx context.Context, start int, end int, overwrite bool) error {
+func (m *ShowCrwaler) Start(ctx context.Context, start int, end int, overwrite bool, opts FetchOptions) error {
 	if end == 0 {
-		end = 350000
+		end = m.maxID
 	}
 	if start == 0 {
-		index, err := m.GetShowProgress()
+		index, err := m.GetShowProgress(ctx)
 		if err != nil {
 			return err
 		}
 		start = index + 1
 	}
 	fmt.Println("Starting crawler for shows from index", start)
-	for i := start; i <= end; i++ {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-			v := i
-
-			if !overwrite {
-				exists, err := m.repo.ItemExists("show", v)
-				if err != nil {
-					fmt.Println("Error getting item exists", err)
-				}
-				if exists {
-					fmt.Println("Skipping item since its found")
-					err = m.repo.UpdateShowProgress(v)
-					if err != nil {
-						fmt.Println("Error storing show progress", err)
-					}
-					continue
+	c := crawl{
+		repo:      m.repo,
+		tp:        "show",
+		overwrite: overwrite,
+		exists: func(ctx context.Context, v int) (bool, error) {
+			return m.repo.ItemExists(ctx, "show", v)
+		},
+		fetch: func(ctx context.Context, v int) error {
+			return m.Fetch(ctx, v, opts)
+		},
+		checkpoint: m.repo.UpdateShowProgress,
+	}
+	return c.run(ctx, start-1, rangePages(end))
+}
+
+func (m *ShowCrwaler) GetShowProgress(ctx context.Context) (int, error) {
+	return m.repo.GetShowProgress(ctx)
+}
+
+// Fetch downloads a single show and stores it, recording it in not_found or
+// failed when that does not work. It does not touch the crawl progress.
+func (m *ShowCrwaler) Fetch(ctx context.Context, v int, opts FetchOptions) error {
+	storeCtx := storeContext(ctx)
+
+	details, err := m.usecase.GetShowDetails(ctx, fmt.Sprintf("%d", v), m.at)
+	var missing *MissingSeasonsError
+	if errors.As(err, &missing) {
+		// The show is kept without them, the failure names the seasons.
+		fmt.Println(missing)
+		m.repo.InsertError(storeCtx, v, "show", missing)
+		err = nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Println("Error getting show details for", v)
+		if isNotFound(err) {
+			m.repo.InsertNotFound(storeCtx, v, "show")
+		} else {
+			m.repo.InsertError(storeCtx, v, "show", err)
+		}
+		return err
+	}
+
+	err = m.validator.Record(storeCtx, "show", v, m.validator.Show(v, details))
+	if err != nil {
+		return err
+	}
+
+	bt, err := json.Marshal(details)
+	if err != nil {
+		fmt.Printf("Error marhsalling show data %d %v\n", v, err)
+		m.repo.InsertError(storeCtx, v, "show", err)
+		return err
+	}
+
+	err = m.repo.StoreDetails(storeCtx, v, bt, "show")
+	if err != nil {
+		fmt.Println("Error storing data in db")
+		m.repo.InsertError(storeCtx, v, "show", err)
+		return err
+	}
+
+	err = m.repo.StoreUpcomingEpisodes(storeCtx, v)
+	if err != nil {
+		fmt.Println("Error storing show upcoming episodes", v, err)
+	}
+
+	languages := opts.Languages
+	if len(languages) == 0 {
+		languages = m.languages
+	}
+	err = m.repo.StoreTranslations(storeCtx, "show", v, languages, translationRecords(details.Translations, details.AlternativeTitles, languages))
+	if err != nil {
+		fmt.Println("Error storing show translations", v, err)
+	}
+
+	err = m.repo.StoreCertifications(storeCtx, "show", v, showCertifications(details.ContentRatings))
+	if err != nil {
+		fmt.Println("Error storing show certifications", v, err)
+	}
+
+	err = m.repo.StoreWatchProviders(storeCtx, "show", v, watchOffers(details.WatchProviders), time.Now())
+	if err != nil {
+		fmt.Println("Error storing show watch providers", v, err)
+	}
+
+	err = m.repo.StoreKeywords(storeCtx, "show", v, details.Keywords.All())
+	if err != nil {
+		fmt.Println("Error storing show keywords", v, err)
+	}
+
+	if opts.Deep {
+		return m.fetchEpisodes(ctx, v, details)
+	}
+	return nil
+}
+
+// fetchEpisodes fetches and stores every episode of the show on its own.
+// Episodes that fail are recorded in failed with type episode and the show
+// id, the show itself is kept.
+func (m *ShowCrwaler) fetchEpisodes(ctx context.Context, v int, details models.TMDBShow) error {
+	storeCtx := storeContext(ctx)
+	for _, season := range details.Seasons {
+		for _, ep := range season.Episodes {
+			episode, err := m.usecase.GetEpisodeDetails(ctx, fmt.Sprintf("%d", v), season.SeasonNumber, ep.EpisodeNumber, m.at)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
 				}
+				fmt.Printf("Error getting episode details for %d S%dE%d\n", v, season.SeasonNumber, ep.EpisodeNumber)
+				m.repo.InsertError(storeCtx, v, "episode", fmt.Errorf("S%dE%d: %w", season.SeasonNumber, ep.EpisodeNumber, err))
+				continue
 			}
 
-			exists, err := m.repo.NotFoundExists("show", v)
+			bt, err := json.Marshal(episode)
 			if err != nil {
-				fmt.Println("Error getting not found", err)
-			}
-			if exists {
-				fmt.Println("Skipping item since it does not exists")
-				err = m.repo.UpdateShowProgress(v)
-				if err != nil {
-					fmt.Println("Error storing show progress", err)
-				}
+				fmt.Printf("Error marshalling episode data %d %v\n", v, err)
+				m.repo.InsertError(storeCtx, v, "episode", err)
 				continue
 			}
 
-			details, err := m.usecase.GetShowDetails(fmt.Sprintf("%d", v), m.at)
+			err = m.repo.StoreEpisode(storeCtx, v, season.SeasonNumber, ep.EpisodeNumber, episode.ID, bt)
 			if err != nil {
-				fmt.Println("Error getting show details for", v)
-				if err.Error() == "not found" {
-					m.repo.InsertNotFound(v, "show")
-				} else {
-					m.repo.InsertError(v, "show", err.Error())
-				}
-			} else {
-				bt, err := json.Marshal(details)
-				if err != nil {
-					fmt.Printf("Error marhsalling show data %d %v\n", v, err)
-					m.repo.InsertError(v, "show", err.Error())
-				} else {
-					err := m.repo.StoreDetails(v, bt, "show")
-					if err != nil {
-						fmt.Println("Error storing data in db")
-						m.repo.InsertError(v, "show", err.Error())
-						continue
-					}
-					err = m.repo.UpdateShowProgress(v)
-					if err != nil {
-						fmt.Println("Error storing show progress", err)
-					} else {
-						fmt.Println("Show details stored for", v)
-					}
-				}
+				fmt.Println("Error storing episode in db", err)
+				m.repo.InsertError(storeCtx, v, "episode", err)
 			}
 		}
-
 	}
-
+	fmt.Println("Episode details stored for", v)
 	return nil
 }
-
-func (m *ShowCrwaler) GetShowProgress() (int, error) {
-	return m.repo.GetShowProgress()
-}
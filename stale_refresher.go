@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StaleRefresher refetches the stored items whose data is most likely out
+// of date, a budgeted number per run.
+type StaleRefresher struct {
+	repo     *Repo
+	interval time.Duration
+	budget   int
+	minAge   time.Duration
+	fetchers map[string]func(ctx context.Context, v int, opts FetchOptions) error
+}
+
+func NewStaleRefresher(
+	repo *Repo,
+	interval time.Duration,
+	budget int,
+	minAge time.Duration,
+	movieC *MovieCrwaler,
+	showC *ShowCrwaler,
+	personC *PersonCrawler,
+) *StaleRefresher {
+	return &StaleRefresher{
+		repo:     repo,
+		interval: interval,
+		budget:   budget,
+		minAge:   minAge,
+		fetchers: map[string]func(ctx context.Context, v int, opts FetchOptions) error{
+			"movie":  movieC.Fetch,
+			"show":   showC.Fetch,
+			"person": personC.Fetch,
+		},
+	}
+}
+
+// Start runs once and then again on every interval until ctx is cancelled.
+// budget overrides the configured one when positive.
+func (s *StaleRefresher) Start(ctx context.Context, types []string, budget int) error {
+	if err := s.RunOnce(ctx, types, budget); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.RunOnce(ctx, types, budget); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				fmt.Println("Scheduled stale refresh failed", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// RunOnce refetches up to budget of the stalest items of the given types,
+// every type when empty.
+func (s *StaleRefresher) RunOnce(ctx context.Context, types []string, budget int) error {
+	if len(types) == 0 {
+		types = []string{"movie", "show", "person"}
+	}
+	for _, tp := range types {
+		if _, ok := s.fetchers[tp]; !ok {
+			return fmt.Errorf("Invalid type %s", tp)
+		}
+	}
+	if budget <= 0 {
+		budget = s.budget
+	}
+
+	items, err := s.repo.GetStaleItems(ctx, types, s.minAge, budget)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Refreshing %d stale items\n", len(items))
+
+	refreshed := 0
+	for _, it := range items {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		stored, err := fetchListed(ctx, s.fetchers[it.Type], it.ID, FetchOptions{})
+		if err != nil {
+			return err
+		}
+		if !stored {
+			// The failed attempt pushes the item back.
+			if err := s.repo.MarkAttempted(ctx, it.Type, it.ID); err != nil {
+				fmt.Println("Error marking refresh attempt of", it.Type, it.ID, err)
+			}
+			continue
+		}
+		refreshed++
+	}
+	fmt.Printf("Refreshed %d of %d stale items\n", refreshed, len(items))
+	return nil
+}
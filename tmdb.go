@@ -1,35 +1,116 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 	"tmdb_scraper/models"
 )
 
+// RequestTimeout is the deadline applied to every single TMDB request,
+// including the time spent queued in the HttpClient dispatcher.
+const RequestTimeout = 10 * time.Second
+
 type Usecase struct {
 	tmdbApiBaseUrl string
 	client         *HttpClient
+	requestTimeout time.Duration
+	// archive keeps the raw responses when set. With replay the responses
+	// are read back from it instead of asking TMDB.
+	archive *Repo
+	replay  bool
+	// drift records how payloads differ from the models when set.
+	drift *Repo
+	// specials fetches season 0 of shows with the other seasons.
+	specials bool
+	// breaker holds requests back while TMDB keeps failing, nil in replay
+	// mode.
+	breaker *CircuitBreaker
 }
 
-func NewUsecase(tmdbApiBaseUrl string, client *HttpClient) *Usecase {
+// NewUsecase builds the TMDB client. archive and drift may be nil to not
+// keep raw responses or not look for schema drift.
+func NewUsecase(tmdbApiBaseUrl string, client *HttpClient, requestTimeout time.Duration, archive *Repo, drift *Repo, specials bool, breaker *CircuitBreaker) *Usecase {
 	return &Usecase{
 		tmdbApiBaseUrl: tmdbApiBaseUrl,
 		client:         client,
+		requestTimeout: requestTimeout,
+		archive:        archive,
+		drift:          drift,
+		specials:       specials,
+		breaker:        breaker,
 	}
 }
 
-func (u *Usecase) GetMovieDetails(id string, at string) (models.TMDBMovie, error) {
-	var response models.TMDBMovie
-	url := fmt.Sprintf(
-		"%s/movie/%s?append_to_response=credits,images,external_ids,similar,belongs_to_collection,videos,recommendations",
-		u.tmdbApiBaseUrl,
-		id,
-	)
+// Replay returns a Usecase that answers every request from the archived
+// responses and never talks to TMDB. The drift was recorded when they were
+// fetched and is not looked for again.
+func (u *Usecase) Replay(archive *Repo) *Usecase {
+	return &Usecase{
+		tmdbApiBaseUrl: u.tmdbApiBaseUrl,
+		requestTimeout: u.requestTimeout,
+		archive:        archive,
+		replay:         true,
+		specials:       u.specials,
+	}
+}
+
+// endpoint is the path of url below the api base url, without the query.
+func (u *Usecase) endpoint(url string) string {
+	path, _, _ := strings.Cut(strings.TrimPrefix(url, u.tmdbApiBaseUrl), "?")
+	return path
+}
 
-	req, _ := http.NewRequest("GET", url, nil)
+// doGet sends an authenticated GET through the rate limited client and
+// returns the fully read body. Failed requests and responses other than
+// 200 are returned as *APIError. Responses are archived or, in replay
+// mode, read from the archive. While the circuit breaker is open it waits.
+func (u *Usecase) doGet(ctx context.Context, url string, at string) ([]byte, error) {
+	path := strings.TrimPrefix(url, u.tmdbApiBaseUrl)
+	if u.replay {
+		status, body, err := u.archive.LoadRawResponse(ctx, path)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%s is not archived", path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return body, checkStatus(u.endpoint(url), status, body)
+	}
+
+	if u.breaker == nil {
+		return u.send(ctx, url, at)
+	}
+	probe, err := u.breaker.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := u.send(ctx, url, at)
+	if ctx.Err() != nil {
+		// Stopped, not a failure of TMDB.
+		u.breaker.Record(probe, ctx.Err())
+	} else {
+		u.breaker.Record(probe, err)
+	}
+	return body, err
+}
+
+// send does the request of doGet.
+func (u *Usecase) send(ctx context.Context, url string, at string) ([]byte, error) {
+	path := strings.TrimPrefix(url, u.tmdbApiBaseUrl)
+	ctx, cancel := context.WithTimeout(ctx, u.requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	req.Header.Add("accept", "application/json")
 	req.Header.Add(
@@ -39,211 +120,312 @@ func (u *Usecase) GetMovieDetails(id string, at string) (models.TMDBMovie, error
 
 	res, err := u.client.Do(req)
 	if err != nil {
-		fmt.Println("Error sending get movie request to TMDB", err)
-		return response, err
+		return nil, &APIError{Kind: ErrNetwork, Endpoint: u.endpoint(url), Err: err}
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		if res.StatusCode == http.StatusNotFound {
-			return response, fmt.Errorf("not found")
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, &APIError{Kind: ErrNetwork, Status: res.StatusCode, Endpoint: u.endpoint(url), Err: err}
+	}
+
+	if u.archive != nil {
+		// The response is in hand, archive it even if ctx is done now.
+		err = u.archive.StoreRawResponse(context.WithoutCancel(ctx), path, res.StatusCode, body)
+		if err != nil {
+			fmt.Println("Error archiving response of", path, err)
 		}
-		fmt.Println("Invalid status code from get movie request to TMDB", res.StatusCode)
-		return response, fmt.Errorf("Getting invalid status code %d for %s", res.StatusCode, id)
 	}
+	return body, checkStatus(u.endpoint(url), res.StatusCode, body)
+}
 
-	body, err := io.ReadAll(res.Body)
+func (u *Usecase) GetMovieDetails(ctx context.Context, id string, at string) (models.TMDBMovie, error) {
+	var response models.TMDBMovie
+	url := fmt.Sprintf(
+		"%s/movie/%s?append_to_response=credits,images,external_ids,similar,belongs_to_collection,videos,recommendations,translations,alternative_titles,release_dates,watch/providers,keywords,reviews",
+		u.tmdbApiBaseUrl,
+		id,
+	)
+
+	body, err := u.doGet(ctx, url, at)
 	if err != nil {
-		fmt.Println("Error reading response of get movie request to TMDB", err)
+		fmt.Println("Error sending get movie request to TMDB", err)
 		return response, err
 	}
 
+	u.checkDrift(ctx, "movie", body, response)
 	err = json.Unmarshal(body, &response)
 	if err != nil {
 		fmt.Println("Error unmarshalling get movie response", err)
-		return response, err
+		return response, decodeError(u.endpoint(url), err)
 	}
 
-	if response.BelongsToCollection.ID != 0 {
+	// The collection itself is stored separately, see MovieCrwaler.
+	if response.BelongsToCollection != nil {
+		response.CollectionID = response.BelongsToCollection.ID
+	}
 
-		url = fmt.Sprintf(
-			"%s/collection/%d",
-			u.tmdbApiBaseUrl,
-			response.BelongsToCollection.ID,
-		)
+	response.Reviews, err = u.remainingReviews(ctx, "movie", id, response.Reviews, at)
+	if err != nil {
+		return response, err
+	}
 
-		req, _ = http.NewRequest("GET", url, nil)
+	return response, nil
+}
 
-		req.Header.Add("accept", "application/json")
-		req.Header.Add(
-			"Authorization",
-			fmt.Sprintf("Bearer %s", at),
-		)
+// GetReviews returns one page of the reviews of a movie or show, tp is
+// movie or tv.
+func (u *Usecase) GetReviews(ctx context.Context, tp string, id string, page int64, at string) (models.Reviews, error) {
+	var reviews models.Reviews
+	url := fmt.Sprintf(
+		"%s/%s/%s/reviews?page=%d",
+		u.tmdbApiBaseUrl,
+		tp,
+		id,
+		page,
+	)
 
-		res, err = u.client.Do(req)
-		if err != nil {
-			fmt.Println("Error sending get collection request to TMDB", err)
-			return response, err
-		}
-		defer res.Body.Close()
+	body, err := u.doGet(ctx, url, at)
+	if err != nil {
+		fmt.Println("Error sending get reviews request to TMDB", err)
+		return reviews, err
+	}
 
-		if res.StatusCode != http.StatusOK {
-			fmt.Println("Invalid status code from get collection request to TMDB", res.StatusCode)
-			return response, err
-		}
+	err = json.Unmarshal(body, &reviews)
+	if err != nil {
+		fmt.Println("Error unmarshalling get reviews response", err)
+		return reviews, decodeError(u.endpoint(url), err)
+	}
+	return reviews, nil
+}
 
-		body, err := io.ReadAll(res.Body)
+// remainingReviews pages through the reviews after the appended first page
+// and returns all of them in one Reviews.
+func (u *Usecase) remainingReviews(ctx context.Context, tp string, id string, first models.Reviews, at string) (models.Reviews, error) {
+	for page := first.Page + 1; page <= first.TotalPages; page++ {
+		next, err := u.GetReviews(ctx, tp, id, page, at)
 		if err != nil {
-			fmt.Println("Error reading response of get collection request to TMDB", err)
-			return response, err
+			return first, err
 		}
+		first.Results = append(first.Results, next.Results...)
+	}
+	return first, nil
+}
 
-		var collection models.Collection
-		err = json.Unmarshal(body, &collection)
-		if err != nil {
-			fmt.Println("Error unmarshalling get collection response", err)
-			return response, err
-		}
-		response.Collection = collection
+func (u *Usecase) GetCollection(ctx context.Context, id string, at string) (models.Collection, error) {
+	var collection models.Collection
+	url := fmt.Sprintf(
+		"%s/collection/%s",
+		u.tmdbApiBaseUrl,
+		id,
+	)
+
+	body, err := u.doGet(ctx, url, at)
+	if err != nil {
+		fmt.Println("Error sending get collection request to TMDB", err)
+		return collection, err
 	}
 
-	return response, nil
+	u.checkDrift(ctx, "collection", body, collection)
+	err = json.Unmarshal(body, &collection)
+	if err != nil {
+		fmt.Println("Error unmarshalling get collection response", err)
+		return collection, decodeError(u.endpoint(url), err)
+	}
+	return collection, nil
 }
 
-func (u *Usecase) GetShowDetails(id string, at string) (models.TMDBShow, error) {
-	var details models.TMDBShow
+// GetCertificationList returns the certifications TMDB knows per country,
+// tp is movie or tv.
+func (u *Usecase) GetCertificationList(ctx context.Context, tp string, at string) (models.CertificationList, error) {
+	var list models.CertificationList
 	url := fmt.Sprintf(
-		"%s/tv/%s?append_to_response=credits,external_ids,images,similar,recommendations,videos",
-		u.tmdbApiBaseUrl, id,
+		"%s/certification/%s/list",
+		u.tmdbApiBaseUrl,
+		tp,
 	)
 
-	req, _ := http.NewRequest("GET", url, nil)
+	body, err := u.doGet(ctx, url, at)
+	if err != nil {
+		fmt.Println("Error sending get certification list request to TMDB", err)
+		return list, err
+	}
 
-	req.Header.Add("accept", "application/json")
-	req.Header.Add(
-		"Authorization",
-		fmt.Sprintf("Bearer %s", at),
-	)
+	err = json.Unmarshal(body, &list)
+	if err != nil {
+		fmt.Println("Error unmarshalling certification list response", err)
+		return list, decodeError(u.endpoint(url), err)
+	}
+	return list, nil
+}
 
-	res, err := u.client.Do(req)
+// getItem fetches an endpoint that needs no further handling into v.
+func (u *Usecase) getItem(ctx context.Context, path string, at string, v any) error {
+	url := fmt.Sprintf("%s%s", u.tmdbApiBaseUrl, path)
+
+	body, err := u.doGet(ctx, url, at)
 	if err != nil {
-		fmt.Println("Error sending get series request to TMDB", err)
-		return details, err
+		fmt.Println("Error sending get", path, "request to TMDB", err)
+		return err
 	}
-	defer res.Body.Close()
 
-	body, err := io.ReadAll(res.Body)
+	err = json.Unmarshal(body, v)
 	if err != nil {
-		fmt.Println("Error reading response of get series request to TMDB", err)
+		fmt.Println("Error unmarshalling", path, "response", err)
+		return decodeError(u.endpoint(url), err)
+	}
+	return nil
+}
+
+// GetCompanyDetails fetches a company with its alternative names and logos.
+// The company endpoints don't take append_to_response.
+func (u *Usecase) GetCompanyDetails(ctx context.Context, id string, at string) (models.TMDBCompany, error) {
+	var details models.TMDBCompany
+	path := fmt.Sprintf("/company/%s", id)
+	if err := u.getItem(ctx, path, at, &details); err != nil {
+		return details, err
+	}
+	if err := u.getItem(ctx, path+"/alternative_names", at, &details.AlternativeNames); err != nil {
+		return details, err
+	}
+	if err := u.getItem(ctx, path+"/images", at, &details.Images); err != nil {
 		return details, err
 	}
+	return details, nil
+}
 
-	if res.StatusCode != http.StatusOK {
-		if res.StatusCode == http.StatusNotFound {
-			return details, fmt.Errorf("not found")
-		}
-		fmt.Println(
-			"Invalid status code from get series request to TMDB",
-			res.StatusCode,
-			string(body),
-		)
-		return details, fmt.Errorf("Got invalid status code %d for %s", res.StatusCode, id)
+// GetNetworkDetails fetches a network with its alternative names and logos.
+func (u *Usecase) GetNetworkDetails(ctx context.Context, id string, at string) (models.TMDBNetwork, error) {
+	var details models.TMDBNetwork
+	path := fmt.Sprintf("/network/%s", id)
+	if err := u.getItem(ctx, path, at, &details); err != nil {
+		return details, err
+	}
+	if err := u.getItem(ctx, path+"/alternative_names", at, &details.AlternativeNames); err != nil {
+		return details, err
+	}
+	if err := u.getItem(ctx, path+"/images", at, &details.Images); err != nil {
+		return details, err
+	}
+	return details, nil
+}
+
+// GetChartPage returns a page of a list like trending/movie/day or
+// movie/popular.
+func (u *Usecase) GetChartPage(ctx context.Context, chart string, page int, at string) (models.ChartPage, error) {
+	var res models.ChartPage
+	err := u.getItem(ctx, fmt.Sprintf("/%s?page=%d", chart, page), at, &res)
+	return res, err
+}
+
+// Discover returns a page of /discover/{movie,tv} for the given filter
+// params, tp is movie or tv.
+func (u *Usecase) Discover(ctx context.Context, tp string, params url.Values, page int, at string) (models.ChartPage, error) {
+	var res models.ChartPage
+	q := url.Values{}
+	for k, v := range params {
+		q[k] = v
+	}
+	q.Set("page", fmt.Sprintf("%d", page))
+	err := u.getItem(ctx, fmt.Sprintf("/discover/%s?%s", tp, q.Encode()), at, &res)
+	return res, err
+}
+
+// GetGenreList returns the genres of movies or shows, tp is movie or tv.
+func (u *Usecase) GetGenreList(ctx context.Context, tp string, at string) (models.TMDBGenreResponse, error) {
+	var res models.TMDBGenreResponse
+	err := u.getItem(ctx, fmt.Sprintf("/genre/%s/list", tp), at, &res)
+	return res, err
+}
+
+func (u *Usecase) GetCountries(ctx context.Context, at string) ([]models.Country, error) {
+	var res []models.Country
+	err := u.getItem(ctx, "/configuration/countries", at, &res)
+	return res, err
+}
+
+func (u *Usecase) GetLanguages(ctx context.Context, at string) ([]models.Language, error) {
+	var res []models.Language
+	err := u.getItem(ctx, "/configuration/languages", at, &res)
+	return res, err
+}
+
+func (u *Usecase) GetConfiguration(ctx context.Context, at string) (models.Configuration, error) {
+	var res models.Configuration
+	err := u.getItem(ctx, "/configuration", at, &res)
+	return res, err
+}
+
+func (u *Usecase) GetShowDetails(ctx context.Context, id string, at string) (models.TMDBShow, error) {
+	var details models.TMDBShow
+	url := fmt.Sprintf(
+		"%s/tv/%s?append_to_response=credits,external_ids,images,similar,recommendations,videos,translations,alternative_titles,content_ratings,watch/providers,keywords,reviews",
+		u.tmdbApiBaseUrl, id,
+	)
+
+	body, err := u.doGet(ctx, url, at)
+	if err != nil {
+		fmt.Println("Error sending get series request to TMDB", err)
+		return details, err
 	}
 
+	u.checkDrift(ctx, "tv", body, details)
 	err = json.Unmarshal(body, &details)
 	if err != nil {
 		fmt.Println("Error unmarshalling show response", err, string(body))
+		return details, decodeError(u.endpoint(url), err)
+	}
+
+	details.Reviews, err = u.remainingReviews(ctx, "tv", id, details.Reviews, at)
+	if err != nil {
 		return details, err
 	}
 
-	var seasons []models.Season
-
-	seasonInOneIteration := 10
-	iterations := int(math.Ceil((float64(len(details.Seasons)) / float64(seasonInOneIteration))))
-	for iteration := range iterations {
-		keys := []string{}
-		url = fmt.Sprintf(
-			"%s/tv/%s?append_to_response=",
-			u.tmdbApiBaseUrl, id,
-		)
-	inner:
-		for i := range seasonInOneIteration {
-			index := i + (seasonInOneIteration * iteration)
-			maxIndex := (seasonInOneIteration - 1) + (seasonInOneIteration * iteration)
-			if index >= len(details.Seasons) {
-				break inner
-			}
-			currSeason := details.Seasons[index]
-			if currSeason.SeasonNumber == 0 {
-				continue inner
-			}
-			seasonKey := fmt.Sprintf("season/%d", currSeason.SeasonNumber)
-			url += seasonKey
-			keys = append(keys, seasonKey)
-			if index < len(details.Seasons)-1 && index < maxIndex {
-				url += ","
-			}
-		}
-		req, _ = http.NewRequest("GET", url, nil)
+	// A show with missing seasons is still returned, with the error.
+	details.Seasons, err = u.getSeasons(ctx, id, details.Seasons, at)
+	return details, err
+}
 
-		req.Header.Add("accept", "application/json")
-		req.Header.Add(
-			"Authorization",
-			fmt.Sprintf("Bearer %s", at),
-		)
+func (u *Usecase) GetEpisodeDetails(ctx context.Context, showID string, season int64, episode int64, at string) (models.Episode, error) {
+	var details models.Episode
+	url := fmt.Sprintf(
+		"%s/tv/%s/season/%d/episode/%d?append_to_response=credits,external_ids,images",
+		u.tmdbApiBaseUrl, showID, season, episode,
+	)
 
-		res, err = u.client.Do(req)
-		if err != nil {
-			fmt.Println("Error sending get series request to TMDB", err)
-			return details, err
-		}
+	body, err := u.doGet(ctx, url, at)
+	if err != nil {
+		fmt.Println("Error sending get episode request to TMDB", err)
+		return details, err
+	}
 
-		defer res.Body.Close()
-		body, err = io.ReadAll(res.Body)
-		if err != nil {
-			fmt.Println("Error reading response of get series request to TMDB", err)
-			return details, err
-		}
+	err = json.Unmarshal(body, &details)
+	if err != nil {
+		fmt.Println("Error unmarshalling episode response", err, string(body))
+		return details, decodeError(u.endpoint(url), err)
+	}
 
-		if res.StatusCode != http.StatusOK {
-			fmt.Println(
-				"Invalid status code from get series request to TMDB",
-				res.StatusCode,
-				string(body),
-			)
-			return details, fmt.Errorf("Got invalid status code %d for  %s", res.StatusCode, id)
-		}
+	return details, nil
+}
 
-		rawMap := make(map[string]json.RawMessage, 0)
-		err = json.Unmarshal(body, &rawMap)
-		if err != nil {
-			return details, fmt.Errorf(
-				"Error unmarshalling season response %v %s\n",
-				err,
-				string(body),
-			)
-		}
-		for _, k := range keys {
-			var temp models.Season
-			data, ok := rawMap[k]
-			if !ok {
-				fmt.Println("could not find data for season ", k)
-				continue
-			}
-			err = json.Unmarshal(data, &temp)
-			if err != nil {
-				fmt.Println("Error unmarshalling show season response", err)
-				return details, fmt.Errorf(
-					"Error unmarshalling show season response %v %s\n",
-					err,
-					string(data),
-				)
-			}
-			seasons = append(seasons, temp)
-		}
+func (u *Usecase) GetPersonDetails(ctx context.Context, id string, at string) (models.TMDBPerson, error) {
+	var details models.TMDBPerson
+	url := fmt.Sprintf(
+		"%s/person/%s?append_to_response=combined_credits,external_ids,images",
+		u.tmdbApiBaseUrl, id,
+	)
+
+	body, err := u.doGet(ctx, url, at)
+	if err != nil {
+		fmt.Println("Error sending get person request to TMDB", err)
+		return details, err
 	}
 
-	details.Seasons = seasons
+	err = json.Unmarshal(body, &details)
+	if err != nil {
+		fmt.Println("Error unmarshalling person response", err, string(body))
+		return details, decodeError(u.endpoint(url), err)
+	}
 
 	return details, nil
 }
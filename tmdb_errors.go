@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorKind classifies why a TMDB request failed. It is stored with the
+// failures so they can be listed and retried by kind.
+type ErrorKind string
+
+const (
+	ErrNotFound     ErrorKind = "not_found"
+	ErrRateLimited  ErrorKind = "rate_limited"
+	ErrUnauthorized ErrorKind = "unauthorized"
+	ErrUpstream5xx  ErrorKind = "upstream_5xx"
+	// ErrStatus is any other unexpected status, like a 400.
+	ErrStatus  ErrorKind = "status"
+	ErrDecode  ErrorKind = "decode"
+	ErrNetwork ErrorKind = "network"
+	// ErrMissingSeasons is a show stored without some of its seasons, see
+	// MissingSeasonsError.
+	ErrMissingSeasons ErrorKind = "missing_seasons"
+	// ErrInvalid is an item that broke a validation rule, see Validator.
+	ErrInvalid ErrorKind = "invalid"
+	// ErrOther is everything that did not come from TMDB, like a failed
+	// insert.
+	ErrOther ErrorKind = "other"
+)
+
+var ErrorKinds = []ErrorKind{ErrNotFound, ErrRateLimited, ErrUnauthorized, ErrUpstream5xx, ErrStatus, ErrDecode, ErrNetwork, ErrMissingSeasons, ErrInvalid, ErrOther}
+
+// APIError is returned by Usecase for every request that did not yield a
+// usable response.
+type APIError struct {
+	Kind ErrorKind
+	// Status is the HTTP status, 0 when there was no response.
+	Status int
+	// Endpoint is the request path below the api base url.
+	Endpoint string
+	Err      error
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Endpoint, e.Kind)
+	if e.Status != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.Status)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// checkStatus returns the *APIError for a response that is not a 200.
+func checkStatus(endpoint string, status int, body []byte) error {
+	if status == http.StatusOK {
+		return nil
+	}
+	kind := ErrStatus
+	switch {
+	case status == http.StatusNotFound:
+		return &APIError{Kind: ErrNotFound, Status: status, Endpoint: endpoint}
+	case status == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		kind = ErrUnauthorized
+	case status >= 500:
+		kind = ErrUpstream5xx
+	}
+	fmt.Println("Invalid status code from", endpoint, "request to TMDB", status, string(body))
+	return &APIError{Kind: kind, Status: status, Endpoint: endpoint}
+}
+
+func decodeError(endpoint string, err error) error {
+	return &APIError{Kind: ErrDecode, Status: http.StatusOK, Endpoint: endpoint, Err: err}
+}
+
+// errorKind is the kind of err, ErrOther when it is none of the errors
+// classified here.
+func errorKind(err error) ErrorKind {
+	var apiErr *APIError
+	var missing *MissingSeasonsError
+	var invalid *ValidationError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Kind
+	case errors.As(err, &missing):
+		return ErrMissingSeasons
+	case errors.As(err, &invalid):
+		return ErrInvalid
+	}
+	return ErrOther
+}
+
+func isNotFound(err error) bool {
+	return errorKind(err) == ErrNotFound
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"tmdb_scraper/models"
+)
+
+// TranslationRecord is what the translations table stores per (type,
+// tmdb_id, iso_639_1, iso_3166_1). Title holds the show name for shows.
+type TranslationRecord struct {
+	ISO639_1          string                    `json:"iso_639_1"`
+	ISO3166_1         string                    `json:"iso_3166_1"`
+	Title             string                    `json:"title"`
+	Overview          string                    `json:"overview"`
+	Tagline           string                    `json:"tagline"`
+	Homepage          string                    `json:"homepage"`
+	Runtime           int64                     `json:"runtime,omitempty"`
+	AlternativeTitles []models.AlternativeTitle `json:"alternative_titles,omitempty"`
+}
+
+func (t TranslationRecord) Tag() string {
+	if t.ISO3166_1 == "" {
+		return t.ISO639_1
+	}
+	return t.ISO639_1 + "-" + t.ISO3166_1
+}
+
+var languageTag = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+
+// validLanguageTag accepts "de" and "de-DE" style tags.
+func validLanguageTag(tag string) bool {
+	return languageTag.MatchString(tag)
+}
+
+// matchesLanguage reports whether a translation in lang/country is wanted.
+// "de" wants German for every country, "de-AT" only the Austrian one and
+// an empty list wants everything.
+func matchesLanguage(languages []string, lang string, country string) bool {
+	if len(languages) == 0 {
+		return true
+	}
+	for _, l := range languages {
+		tagLang, tagCountry, _ := strings.Cut(l, "-")
+		if tagLang == lang && (tagCountry == "" || tagCountry == country) {
+			return true
+		}
+	}
+	return false
+}
+
+// translationRecords projects the appended translations and alternative
+// titles of a movie or show onto the records to store, keeping only the
+// requested languages. Alternative titles are attached to the records of
+// their country.
+func translationRecords(tr models.Translations, alt models.AlternativeTitles, languages []string) []TranslationRecord {
+	titles := alt.All()
+	var res []TranslationRecord
+	for _, t := range tr.Translations {
+		if !matchesLanguage(languages, t.ISO639_1, t.ISO3166_1) {
+			continue
+		}
+		rec := TranslationRecord{
+			ISO639_1:  t.ISO639_1,
+			ISO3166_1: t.ISO3166_1,
+			Title:     t.Data.Title,
+			Overview:  t.Data.Overview,
+			Tagline:   t.Data.Tagline,
+			Homepage:  t.Data.Homepage,
+			Runtime:   t.Data.Runtime,
+		}
+		if rec.Title == "" {
+			rec.Title = t.Data.Name
+		}
+		for _, at := range titles {
+			if at.ISO3166_1 == t.ISO3166_1 {
+				rec.AlternativeTitles = append(rec.AlternativeTitles, at)
+			}
+		}
+		res = append(res, rec)
+	}
+	return res
+}
+
+// requestedLanguages returns the languages a read request asks for, most
+// preferred first: ?lang=de-DE,fr wins over the Accept-Language header.
+func requestedLanguages(r *http.Request) []string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		var res []string
+		for _, l := range strings.Split(lang, ",") {
+			if l = normalizeLanguageTag(l); l != "" {
+				res = append(res, l)
+			}
+		}
+		return res
+	}
+
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = normalizeLanguageTag(tag)
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	res := make([]string, 0, len(tags))
+	for _, t := range tags {
+		res = append(res, t.tag)
+	}
+	return res
+}
+
+// normalizeLanguageTag turns "de-de" or "de_DE" into "de-DE" and drops
+// anything that is not a plain language(-country) tag, like "*".
+func normalizeLanguageTag(tag string) string {
+	lang, country, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+	tag = strings.ToLower(lang)
+	if country != "" {
+		tag += "-" + strings.ToUpper(country)
+	}
+	if !validLanguageTag(tag) {
+		return ""
+	}
+	return tag
+}
+
+// bestTranslation picks the translation for the most preferred language,
+// trying the exact country before any country of the same language.
+func bestTranslation(records []TranslationRecord, languages []string) (TranslationRecord, bool) {
+	for _, l := range languages {
+		lang, country, _ := strings.Cut(l, "-")
+		if country != "" {
+			for _, rec := range records {
+				if rec.ISO639_1 == lang && rec.ISO3166_1 == country {
+					return rec, true
+				}
+			}
+		}
+		for _, rec := range records {
+			if rec.ISO639_1 == lang {
+				return rec, true
+			}
+		}
+	}
+	return TranslationRecord{}, false
+}
+
+// localize overlays the translated texts onto stored details. Empty
+// translated fields keep the original value. The result keeps the shape of
+// the TMDB details, callers send the language next to it, see
+// TranslationRecord.Tag.
+func localize(data []byte, tp string, rec TranslationRecord) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	titleKey := "title"
+	if tp == "show" {
+		titleKey = "name"
+	}
+	set := func(key string, value string) {
+		if value == "" {
+			return
+		}
+		raw, _ := json.Marshal(value)
+		fields[key] = raw
+	}
+	set(titleKey, rec.Title)
+	set("overview", rec.Overview)
+	set("tagline", rec.Tagline)
+	set("homepage", rec.Homepage)
+
+	return json.Marshal(fields)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"tmdb_scraper/models"
+)
+
+// The rules a Validator can check, see validation.rules in the config.
+const (
+	RuleIDMatch        = "id_match"
+	RuleRequiredFields = "required_fields"
+	RuleSeasonCount    = "season_count"
+	RuleEpisodeCount   = "episode_count"
+)
+
+var ValidationRules = []string{RuleIDMatch, RuleRequiredFields, RuleSeasonCount, RuleEpisodeCount}
+
+// Violation is one rule an item broke.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned for an item that is not stored because it
+// broke a rule.
+type ValidationError struct {
+	Type       string
+	ID         int
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		msgs[i] = violation.Rule + ": " + violation.Message
+	}
+	return fmt.Sprintf("invalid %s %d: %s", e.Type, e.ID, strings.Join(msgs, "; "))
+}
+
+// Validator checks fetched items before they are stored and records what
+// it finds in data_quality.
+type Validator struct {
+	repo  *Repo
+	rules []string
+	// reject stores items with violations in failed instead of details.
+	reject bool
+}
+
+// NewValidator checks the given rules, every rule when rules is empty.
+func NewValidator(repo *Repo, rules []string, reject bool) *Validator {
+	if len(rules) == 0 {
+		rules = ValidationRules
+	}
+	return &Validator{
+		repo:   repo,
+		rules:  rules,
+		reject: reject,
+	}
+}
+
+func (v *Validator) enabled(rule string) bool {
+	return slices.Contains(v.rules, rule)
+}
+
+func (v *Validator) checkID(requested int, got int64) []Violation {
+	if !v.enabled(RuleIDMatch) || got == int64(requested) {
+		return nil
+	}
+	return []Violation{{RuleIDMatch, fmt.Sprintf("requested id %d, got %d", requested, got)}}
+}
+
+func (v *Validator) checkRequired(fields map[string]string) []Violation {
+	if !v.enabled(RuleRequiredFields) {
+		return nil
+	}
+	var res []Violation
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		if strings.TrimSpace(fields[name]) == "" {
+			res = append(res, Violation{RuleRequiredFields, name + " is empty"})
+		}
+	}
+	return res
+}
+
+func (v *Validator) Movie(id int, details models.TMDBMovie) []Violation {
+	res := v.checkID(id, details.ID)
+	return append(res, v.checkRequired(map[string]string{"title": details.Title})...)
+}
+
+func (v *Validator) Person(id int, details models.TMDBPerson) []Violation {
+	res := v.checkID(id, details.ID)
+	return append(res, v.checkRequired(map[string]string{"name": details.Name})...)
+}
+
+// Show also compares the fetched seasons and their episodes with the
+// counts TMDB reports, which don't include the specials. Only episodes
+// that aired by today count, TMDB lists the announced ones of a running
+// show before it counts them.
+func (v *Validator) Show(id int, details models.TMDBShow) []Violation {
+	res := v.checkID(id, details.ID)
+	res = append(res, v.checkRequired(map[string]string{"name": details.Name})...)
+
+	now := time.Now().UTC()
+	today := models.NewDate(now.Year(), now.Month(), now.Day())
+	var seasons, episodes int64
+	for _, s := range details.Seasons {
+		if s.SeasonNumber == 0 {
+			continue
+		}
+		seasons++
+		for _, e := range s.Episodes {
+			if e.AirDate.Known() && !e.AirDate.After(today.Time) {
+				episodes++
+			}
+		}
+	}
+	if v.enabled(RuleSeasonCount) && seasons != details.NumberOfSeasons {
+		res = append(res, Violation{RuleSeasonCount, fmt.Sprintf("number_of_seasons is %d, fetched %d", details.NumberOfSeasons, seasons)})
+	}
+	if v.enabled(RuleEpisodeCount) && episodes != details.NumberOfEpisodes {
+		res = append(res, Violation{RuleEpisodeCount, fmt.Sprintf("number_of_episodes is %d, fetched %d", details.NumberOfEpisodes, episodes)})
+	}
+	return res
+}
+
+// Record replaces the violations stored for the item. It returns an error
+// when the item must not be stored, which has then been put in failed.
+func (v *Validator) Record(ctx context.Context, tp string, id int, violations []Violation) error {
+	err := v.repo.StoreViolations(ctx, tp, id, violations)
+	if err != nil {
+		fmt.Println("Error storing data quality of", tp, id, err)
+	}
+	if !v.reject || len(violations) == 0 {
+		return nil
+	}
+
+	err = &ValidationError{Type: tp, ID: id, Violations: violations}
+	v.repo.InsertError(ctx, id, tp, err)
+	return err
+}
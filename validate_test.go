@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+	"tmdb_scraper/models"
+)
+
+func TestValidatorShowCountsAiredEpisodes(t *testing.T) {
+	now := time.Now().UTC()
+	aired := models.NewDate(2011, time.April, 17)
+	today := models.NewDate(now.Year(), now.Month(), now.Day())
+	announced := models.NewDate(now.Year()+1, now.Month(), 1)
+
+	details := models.TMDBShow{
+		ID:               1399,
+		Name:             "Game of Thrones",
+		NumberOfSeasons:  1,
+		NumberOfEpisodes: 2,
+		Seasons: []models.Season{
+			{SeasonNumber: 0, Episodes: []models.Episode{{AirDate: aired}}},
+			{SeasonNumber: 1, Episodes: []models.Episode{
+				{AirDate: aired},
+				{AirDate: today},
+				{AirDate: announced},
+				{},
+			}},
+		},
+	}
+	v := NewValidator(nil, []string{RuleSeasonCount, RuleEpisodeCount}, false)
+	if got := v.Show(1399, details); len(got) != 0 {
+		t.Errorf("Show() = %v, want no violations", got)
+	}
+
+	details.NumberOfEpisodes = 3
+	if got := v.Show(1399, details); len(got) != 1 || got[0].Rule != RuleEpisodeCount {
+		t.Errorf("Show() = %v, want an %s violation", got, RuleEpisodeCount)
+	}
+}
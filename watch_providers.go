@@ -0,0 +1,45 @@
+package main
+
+import "tmdb_scraper/models"
+
+// WatchOffer is one way a title can be watched with a provider in a
+// country. Offer is one of flatrate, rent, buy, ads or free.
+type WatchOffer struct {
+	Region       string
+	ProviderID   int64
+	ProviderName string
+	Offer        string
+}
+
+// watchOffers flattens the appended watch providers of a movie or show.
+func watchOffers(wp models.WatchProviders) []WatchOffer {
+	var res []WatchOffer
+	for region, r := range wp.Results {
+		for offer, providers := range map[string][]models.WatchProvider{
+			"flatrate": r.Flatrate,
+			"rent":     r.Rent,
+			"buy":      r.Buy,
+			"ads":      r.Ads,
+			"free":     r.Free,
+		} {
+			for _, p := range providers {
+				res = append(res, WatchOffer{
+					Region:       region,
+					ProviderID:   p.ProviderID,
+					ProviderName: p.ProviderName,
+					Offer:        offer,
+				})
+			}
+		}
+	}
+	return res
+}
+
+// validWatchOffer reports whether offer names one of the offer types.
+func validWatchOffer(offer string) bool {
+	switch offer {
+	case "flatrate", "rent", "buy", "ads", "free":
+		return true
+	}
+	return false
+}